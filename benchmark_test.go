@@ -1,6 +1,9 @@
 package semver_test
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/Masterminds/semver"
@@ -82,6 +85,39 @@ func benchValidateVersion(c, v string, b *testing.B) {
 	}
 }
 
+func benchCheckBatchVersions() []*semver.Version {
+	versions := make([]*semver.Version, 0, 10000)
+	for i := 0; i < cap(versions); i++ {
+		versions = append(versions, semver.MustParse(fmt.Sprintf("%d.%d.%d", i%5, i%23, i%7)))
+	}
+	return versions
+}
+
+const benchCheckBatchConstraint = "~0.1.0 || ~0.5.0 || ~1.2.0 || ~1.8.0 || ^2.3.0, !=2.4.1 || >=3.2.0, <3.9.0 || ~4.10.0 || ~4.20.0"
+
+func BenchmarkCheckBatch(b *testing.B) {
+	versions := benchCheckBatchVersions()
+	constraint, _ := semver.NewConstraint(benchCheckBatchConstraint)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		constraint.CheckBatch(versions)
+	}
+}
+
+func BenchmarkCheckBatchLoop(b *testing.B) {
+	versions := benchCheckBatchVersions()
+	constraint, _ := semver.NewConstraint(benchCheckBatchConstraint)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := make([]bool, len(versions))
+		for k, v := range versions {
+			r[k] = constraint.Check(v)
+		}
+	}
+}
+
 /* Validate benchmarks, including fails */
 
 func BenchmarkValidateVersionUnary(b *testing.B) {
@@ -155,3 +191,58 @@ func BenchmarkNewVersionMeta(b *testing.B) {
 func BenchmarkNewVersionMetaDash(b *testing.B) {
 	benchNewVersion("1.0.0+metadata-dash", b)
 }
+
+/* VersionSet benchmarks */
+
+func benchAllowList(n int) ([]*semver.Version, []string) {
+	vs := make([]*semver.Version, n)
+	cs := make([]string, n)
+	for i := range vs {
+		vs[i] = semver.MustParse(fmt.Sprintf("1.%d.%d", i/100, i%100))
+		cs[i] = "=" + vs[i].String()
+	}
+	return vs, cs
+}
+
+func BenchmarkVersionSetContains(b *testing.B) {
+	vs, _ := benchAllowList(1000)
+	var s semver.VersionSet
+	for _, v := range vs {
+		s.Add(v)
+	}
+	version := vs[len(vs)-1]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Contains(version)
+	}
+}
+
+func BenchmarkVersionSetConstraintsCheck(b *testing.B) {
+	vs, cs := benchAllowList(1000)
+	constraint, _ := semver.NewConstraint(strings.Join(cs, " || "))
+	version := vs[len(vs)-1]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		constraint.Check(version)
+	}
+}
+
+/* Sort benchmarks */
+
+func BenchmarkSortPrerelease(b *testing.B) {
+	channels := []string{"alpha", "beta", "rc", "SNAPSHOT"}
+	vs := make([]*semver.Version, 0, 1000)
+	for i := 0; i < cap(vs); i++ {
+		vs = append(vs, semver.MustParse(fmt.Sprintf("1.2.3-%s.%d.build.%d",
+			channels[i%len(channels)], (i*7919)%97, (i*104729)%1013)))
+	}
+	c := make(semver.Collection, len(vs))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(c, vs)
+		sort.Sort(c)
+	}
+}
@@ -1,5 +1,7 @@
 package semver
 
+import "sort"
+
 // Collection is a collection of Version instances and implements the sort
 // interface. See the sort package for more details.
 // https://golang.org/pkg/sort/
@@ -22,3 +24,67 @@ func (c Collection) Less(i, j int) bool {
 func (c Collection) Swap(i, j int) {
 	c[i], c[j] = c[j], c[i]
 }
+
+// Search uses binary search to find the index of the first version in a
+// sorted slice that is greater than or equal to target. If there is no such
+// version the length of the slice is returned. It mirrors sort.Search and
+// is useful for finding the point at which to insert a version.
+func Search(versions []*Version, target *Version) int {
+	return sort.Search(len(versions), func(i int) bool {
+		return versions[i].Compare(target) >= 0
+	})
+}
+
+// SortGrouped sorts a copy of the versions and groups them by their major,
+// minor, and patch version. Within a group the pre-releases come before the
+// release they lead up to, and the groups are ordered by version. For
+// example, 1.2.0, 1.3.0-beta.1, 1.3.0-rc.1, and 1.3.0 form the groups
+// [1.2.0] and [1.3.0-beta.1 1.3.0-rc.1 1.3.0]. The passed in slice is not
+// changed.
+func SortGrouped(versions []*Version) [][]*Version {
+	sorted := make(Collection, len(versions))
+	copy(sorted, versions)
+	sort.Stable(sorted)
+
+	var groups [][]*Version
+	for k, v := range sorted {
+		if k > 0 {
+			l := sorted[k-1]
+			if l.major == v.major && l.minor == v.minor && l.patch == v.patch {
+				groups[len(groups)-1] = append(groups[len(groups)-1], v)
+				continue
+			}
+		}
+		groups = append(groups, []*Version{v})
+	}
+
+	return groups
+}
+
+// VersionSet is a set of exact versions with constant time membership tests.
+// Versions are keyed the same way Equal compares them, so build metadata is
+// ignored and numeric pre-release identifiers such as 01 and 1 are the
+// same. It is meant for large allow-lists of exact versions; use Constraints
+// for ranges. The zero value is an empty set ready to use.
+type VersionSet struct {
+	m map[string]struct{}
+}
+
+// Add adds a version to the set.
+func (s *VersionSet) Add(v *Version) {
+	if s.m == nil {
+		s.m = make(map[string]struct{})
+	}
+	s.m[v.key()] = struct{}{}
+}
+
+// Contains tests if a version equal to v is in the set.
+func (s *VersionSet) Contains(v *Version) bool {
+	_, ok := s.m[v.key()]
+	return ok
+}
+
+// Len returns the number of distinct versions in the set.
+func (s *VersionSet) Len() int {
+	return len(s.m)
+}
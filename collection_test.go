@@ -44,3 +44,201 @@ func TestCollection(t *testing.T) {
 		t.Error("Sorting Collection failed")
 	}
 }
+
+func TestCollectionPrereleaseOrder(t *testing.T) {
+	// The precedence example from the SemVer 2.0.0 spec (item 11).
+	e := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+	}
+
+	raw := []string{
+		"1.0.0",
+		"1.0.0-beta.11",
+		"1.0.0-alpha.beta",
+		"1.0.0-rc.1",
+		"1.0.0-alpha",
+		"1.0.0-beta",
+		"1.0.0-alpha.1",
+		"1.0.0-beta.2",
+	}
+
+	vs := make([]*Version, len(raw))
+	for i, r := range raw {
+		v, err := NewVersion(r)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		vs[i] = v
+	}
+
+	sort.Sort(Collection(vs))
+
+	a := make([]string, len(vs))
+	for i, v := range vs {
+		a[i] = v.String()
+	}
+
+	if !reflect.DeepEqual(a, e) {
+		t.Errorf("Sorting Collection failed. Expected %v but got %v", e, a)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	raw := []string{
+		"0.4.2",
+		"1.0.0-alpha",
+		"1.0.0",
+		"1.2.3-beta.1",
+		"1.2.3",
+		"1.3.0",
+		"2.0.0",
+	}
+
+	vs := make([]*Version, len(raw))
+	for i, r := range raw {
+		v, err := NewVersion(r)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		vs[i] = v
+	}
+
+	tests := []struct {
+		target string
+		index  int
+	}{
+		{"0.0.1", 0},
+		{"0.4.2", 0},
+		{"0.9.0", 1},
+		{"1.0.0-alpha", 1},
+		{"1.0.0-beta", 2},
+		{"1.0.0", 2},
+		{"1.0.0+build.1", 2},
+		{"1.2.3-alpha", 3},
+		{"1.2.3-beta.2", 4},
+		{"1.2.3", 4},
+		{"1.2.4", 5},
+		{"2.0.0", 6},
+		{"2.0.1", 7},
+		{"3", 7},
+	}
+
+	for _, tc := range tests {
+		target, err := NewVersion(tc.target)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		if a := Search(vs, target); a != tc.index {
+			t.Errorf("Search for %s returned %d, expected %d", tc.target, a, tc.index)
+		}
+	}
+
+	if a := Search(nil, vs[0]); a != 0 {
+		t.Errorf("Search of an empty slice returned %d", a)
+	}
+}
+
+func TestSortGrouped(t *testing.T) {
+	raw := []string{
+		"1.3.0",
+		"1.2.1",
+		"1.3.0-rc.1",
+		"2.0.0-alpha",
+		"1.2.0",
+		"1.10.0",
+		"1.3.0-beta.2",
+		"1.2.1-beta",
+		"1.3.0-beta.11",
+		"v1.2.0+build.1",
+	}
+
+	vs := make([]*Version, len(raw))
+	for i, r := range raw {
+		v, err := NewVersion(r)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		vs[i] = v
+	}
+
+	e := [][]string{
+		{"1.2.0", "1.2.0+build.1"},
+		{"1.2.1-beta", "1.2.1"},
+		{"1.3.0-beta.2", "1.3.0-beta.11", "1.3.0-rc.1", "1.3.0"},
+		{"1.10.0"},
+		{"2.0.0-alpha"},
+	}
+
+	var a [][]string
+	for _, g := range SortGrouped(vs) {
+		var s []string
+		for _, v := range g {
+			s = append(s, v.String())
+		}
+		a = append(a, s)
+	}
+
+	if !reflect.DeepEqual(a, e) {
+		t.Errorf("Grouping failed. Expected %v but got %v", e, a)
+	}
+
+	if vs[0].String() != "1.3.0" || vs[9].Original() != "v1.2.0+build.1" {
+		t.Error("SortGrouped changed the passed in slice")
+	}
+
+	if g := SortGrouped(nil); len(g) != 0 {
+		t.Errorf("Expected no groups but got %v", g)
+	}
+}
+
+func TestVersionSet(t *testing.T) {
+	var s VersionSet
+	if s.Contains(MustParse("1.0.0")) || s.Len() != 0 {
+		t.Error("Empty VersionSet is not empty")
+	}
+
+	for _, r := range []string{"1.0.0", "v1.2", "1.2.0+build.1", "2.0.0-rc.1"} {
+		s.Add(MustParse(r))
+	}
+
+	if s.Len() != 3 {
+		t.Errorf("Expected 3 versions in the set but got %d", s.Len())
+	}
+
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.0.0", true},
+		{"v1", true},
+		{"1.0.0+build.5", true},
+		{"1.2.0", true},
+		{"1.2.0+build.2", true},
+		{"2.0.0-rc.1", true},
+		{"2.0.0-rc.1+build.1", true},
+		{"2.0.0-rc.01", true},
+		{"v2.0.0-rc.001+build.1", true},
+		{"2.0.0-rc.10", false},
+		{"2.0.0-rc", false},
+		{"2.0.0", false},
+		{"2.0.0-rc.2", false},
+		{"1.0.1", false},
+	}
+
+	for _, tc := range tests {
+		if a := s.Contains(MustParse(tc.version)); a != tc.expected {
+			t.Errorf("Expected Contains(%s) to be %t", tc.version, tc.expected)
+		}
+	}
+}
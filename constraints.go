@@ -1,10 +1,16 @@
 package semver
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Constraints is one or more constraint that a semantic version can be
@@ -13,25 +19,139 @@ type Constraints struct {
 	constraints [][]*constraint
 }
 
+// ConstraintOptions alters how constraints are parsed by
+// NewConstraintWithOptions. The zero value matches the behavior of
+// NewConstraint.
+type ConstraintOptions struct {
+	// PartialAsWildcard treats a partial version used with the = (or no)
+	// operator as a prefix match. For example, 1.2 matches any 1.2.x release
+	// rather than only 1.2.0.
+	PartialAsWildcard bool
+
+	// IncludePrerelease allows versions with a pre-release to satisfy
+	// constraints that do not have a pre-release. For example, 1.5.0-rc1 is
+	// then within the range 1.0.0 - 2.0.0.
+	IncludePrerelease bool
+
+	// MaxTerms is the largest number of terms a constraint may have before
+	// parsing is refused. Terms are separated by || and commas, and each of
+	// the terms written one after the other (e.g., >=1.0.0<2.0.0) counts as
+	// a term. When zero or less, DefaultMaxTerms is used.
+	MaxTerms int
+
+	// AndSeparator separates the terms that must all be satisfied, for
+	// formats where a comma can not be used (e.g., && or ;). When empty, a
+	// comma is used. Or terms are always separated by ||, so the separator
+	// can not contain a |. It also can not contain anything that is part of
+	// a term (letters, digits, whitespace, or any of -.+*<>=!~^) or a comma,
+	// and NewConstraintWithOptions returns an error if it does.
+	AndSeparator string
+
+	// DisallowOperatorAliases rejects the => and =< operators, which are
+	// aliases of >= and <=, with an unknown operator error. This allows
+	// only the canonical form of each operator to be used.
+	DisallowOperatorAliases bool
+}
+
+// DefaultMaxTerms is the number of terms a constraint may have when no
+// limit is set in the ConstraintOptions. It guards against unreasonably large
+// constraints, such as those from untrusted input, using excessive memory.
+const DefaultMaxTerms = 1000
+
 // NewConstraint returns a Constraints instance that a Version instance can
 // be checked against. If there is a parse error it will be returned.
+//
+// An empty, or whitespace only, constraint matches any version in the same
+// way as *. As with *, versions with a pre-release do not match unless
+// pre-releases are included with ConstraintOptions.IncludePrerelease.
 func NewConstraint(c string) (*Constraints, error) {
+	return NewConstraintWithOptions(c, ConstraintOptions{})
+}
+
+// NewConstraintWithOptions returns a Constraints instance parsed using the
+// passed in options. If there is a parse error it will be returned.
+func NewConstraintWithOptions(c string, opts ConstraintOptions) (*Constraints, error) {
+	return parseConstraints(c, opts, false)
+}
+
+// NewStrictConstraint returns a Constraints instance that only permits
+// explicit operators with complete versions, such as >= 1.2.0, < 2.0.0. The
+// shorthand ^, ~, and ~> operators, wildcards, partial versions, and hyphen
+// ranges are rejected with an error describing the term at fault so that
+// the versions a constraint permits are plain to see. A version without an
+// operator is rejected as well, so an exact match is written as = 1.2.0.
+func NewStrictConstraint(c string) (*Constraints, error) {
+	return parseConstraints(c, ConstraintOptions{}, true)
+}
+
+func parseConstraints(c string, opts ConstraintOptions, strict bool) (*Constraints, error) {
+	if strings.TrimSpace(c) == "" {
+		if strict {
+			return nil, errors.New("empty strict constraint")
+		}
+		c = "*"
+	}
+
+	max := opts.MaxTerms
+	if max <= 0 {
+		max = DefaultMaxTerms
+	}
+	sep := opts.AndSeparator
+	if sep == "" {
+		sep = ","
+	} else if sep != "," {
+		if err := checkAndSeparator(sep); err != nil {
+			return nil, err
+		}
+	}
 
-	// Rewrite - ranges into a comparison operation.
-	c = rewriteRange(c)
+	// Count the terms before doing any work so an enormous constraint is
+	// rejected without allocating for each of its terms.
+	n := strings.Count(c, "||") + strings.Count(c, sep) + 1
+	if n > max {
+		return nil, fmt.Errorf("constraint has %d terms, exceeding the limit of %d", n, max)
+	}
+
+	// The rest of the parsing, including the rewritten ranges, separates
+	// terms with a comma.
+	in := c
+	if sep != "," {
+		if strings.Contains(c, ",") {
+			return nil, fmt.Errorf("unexpected ',' in constraint using separator '%s': %s", sep, in)
+		}
+		c = strings.Replace(c, sep, ",", -1)
+	}
 
 	ors := strings.Split(c, "||")
 	or := make([][]*constraint, len(ors))
 	for k, v := range ors {
 		cs := strings.Split(v, ",")
-		result := make([]*constraint, len(cs))
+		result := make([]*constraint, 0, len(cs))
 		for i, s := range cs {
-			pc, err := parseConstraint(s)
+			s = strings.TrimSpace(s)
+			if s == "" {
+				return nil, &ConstraintParseError{
+					Or:  k,
+					And: i,
+					Err: fmt.Errorf("empty term in constraint: %s", in),
+				}
+			}
+
+			// Terms written one after the other are split apart when the
+			// term is parsed, so each of them is counted before then.
+			if ops := countOperators(s); ops > 1 {
+				n += ops - 1
+				if n > max {
+					return nil, fmt.Errorf("constraint has at least %d terms, exceeding the limit of %d", n, max)
+				}
+			}
+
+			pcs, err := parseTerm(s, opts, strict)
 			if err != nil {
-				return nil, err
+				return nil, &ConstraintParseError{Term: s, Or: k, And: i, Err: err}
 			}
 
-			result[i] = pc
+			result = append(result, pcs...)
 		}
 		or[k] = result
 	}
@@ -40,6 +160,163 @@ func NewConstraint(c string) (*Constraints, error) {
 	return o, nil
 }
 
+// checkAndSeparator returns an error if the separator could be mistaken for
+// || or for part of a term, such as a version, an operator, or a range.
+func checkAndSeparator(sep string) error {
+	for _, r := range sep {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) ||
+			strings.ContainsRune("|,-.+*<>=!~^", r) {
+			return fmt.Errorf("separator '%s' can not contain '%c'", sep, r)
+		}
+	}
+	return nil
+}
+
+// ConstraintParseError is returned when a term of a constraint can not be
+// parsed. It records where in the constraint the term is so the problem can
+// be pointed out to the user.
+type ConstraintParseError struct {
+	// The term that could not be parsed, without surrounding whitespace.
+	Term string
+
+	// The index of the || separated group the term is in.
+	Or int
+
+	// The index of the term within its group. A hyphen range counts as a
+	// single term.
+	And int
+
+	// The reason the term could not be parsed.
+	Err error
+}
+
+func (e *ConstraintParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Term is a single operator and version within a constraint. For example,
+// the constraint >= 1.2.0 is the Term{Operator: ">=", Version: 1.2.0}.
+type Term struct {
+	Operator string
+	Version  *Version
+}
+
+// NewConstraintFromTerms returns a Constraints instance built from terms
+// rather than parsed from a string. Each group of terms is ANDed together and
+// the groups are ORed. The operators are the same as those in a constraint
+// string (e.g., >=, ~, ^). Wildcards and ranges can not be expressed as terms.
+func NewConstraintFromTerms(groups [][]Term) (*Constraints, error) {
+	if len(groups) == 0 {
+		return nil, errors.New("no terms in constraint")
+	}
+
+	or := make([][]*constraint, len(groups))
+	for k, g := range groups {
+		if len(g) == 0 {
+			return nil, fmt.Errorf("empty term group %d in constraint", k)
+		}
+
+		result := make([]*constraint, len(g))
+		for i, t := range g {
+			if _, ok := constraintOps[t.Operator]; !ok {
+				return nil, fmt.Errorf("unknown operator '%s' in constraint", t.Operator)
+			}
+			if t.Version == nil {
+				return nil, fmt.Errorf("missing version for operator '%s' in constraint", t.Operator)
+			}
+
+			result[i] = newConstraint(t.Operator, t.Version)
+		}
+		or[k] = result
+	}
+
+	return &Constraints{constraints: or}, nil
+}
+
+// MinimalConstraint returns the narrowest constraints that are satisfied by
+// all of the versions. The constraints are >= the lowest version, <= the
+// highest version, or = the version when there is only one distinct version.
+// A caret is not used even when the versions share a major version, as it
+// would also allow versions above the highest one. When any of the versions
+// has a pre-release, pre-releases are allowed by the terms as they are with
+// ConstraintOptions.IncludePrerelease. An error is returned if there are no
+// versions.
+func MinimalConstraint(versions []*Version) (*Constraints, error) {
+	if len(versions) == 0 {
+		return nil, errors.New("no versions for constraint")
+	}
+
+	min, max := versions[0], versions[0]
+	pre := false
+	for _, v := range versions {
+		if v == nil {
+			return nil, errors.New("nil version for constraint")
+		}
+		if v.LessThan(min) {
+			min = v
+		}
+		if v.GreaterThan(max) {
+			max = v
+		}
+		if v.pre != "" {
+			pre = true
+		}
+	}
+
+	terms := []Term{{Operator: ">=", Version: min}, {Operator: "<=", Version: max}}
+	if min.Equal(max) {
+		terms = []Term{{Operator: "=", Version: min}}
+	}
+
+	cs, err := NewConstraintFromTerms([][]Term{terms})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cs.constraints[0] {
+		c.includePrerelease = pre
+	}
+
+	return cs, nil
+}
+
+// MinimalVersionSatisfying returns the lowest of the candidate versions that
+// satisfies every one of the constraints, as used for minimal version
+// selection. False is returned when no candidate satisfies all of them. With
+// no constraints the lowest candidate is returned.
+func MinimalVersionSatisfying(css []*Constraints, candidates []*Version) (*Version, bool) {
+	var min *Version
+	for _, v := range candidates {
+		if min != nil && !v.LessThan(min) {
+			continue
+		}
+
+		joy := true
+		for _, cs := range css {
+			if !cs.Check(v) {
+				joy = false
+				break
+			}
+		}
+		if joy {
+			min = v
+		}
+	}
+
+	return min, min != nil
+}
+
+// MatchChannel returns a Constraints instance that is satisfied by any
+// version whose pre-release channel, as returned by PrereleaseChannel, is
+// name. Versions without a pre-release only match an empty name.
+func MatchChannel(name string) *Constraints {
+	c := &constraint{
+		function: constraintChannel,
+		msg:      "%s is not in the %s channel",
+		orig:     name,
+	}
+	return &Constraints{constraints: [][]*constraint{{c}}}
+}
+
 // Check tests if a version satisfies the constraints.
 func (cs Constraints) Check(v *Version) bool {
 	// loop over the ORs and check the inner ANDs
@@ -60,6 +337,100 @@ func (cs Constraints) Check(v *Version) bool {
 	return false
 }
 
+// CheckBatch tests each of the versions against the constraints. The
+// returned slice has the result of Check for the version at the same index.
+// The constraints are converted into sorted version ranges once, so for
+// many versions this is faster than calling Check on each of them. Versions
+// with a pre-release, and constraints that can not be expressed as version
+// ranges, are tested with Check.
+func (cs Constraints) CheckBatch(versions []*Version) []bool {
+	r := make([]bool, len(versions))
+	is, ok := cs.intervals()
+
+	// A < or <= with a wildcard (e.g., <= 1.2.x) compares the minor version
+	// even when the major version is lower, which version ranges do not
+	// model, so those constraints are left to Check.
+	for _, o := range cs.constraints {
+		for _, c := range o {
+			if c.dirty && (c.op == "<" || c.op == "<=" || c.op == "=<") {
+				ok = false
+			}
+		}
+	}
+
+	for k, v := range versions {
+		if ok && v.pre == "" {
+			r[k] = is.contains(v)
+		} else {
+			r[k] = cs.Check(v)
+		}
+	}
+	return r
+}
+
+// CheckOptions alters how a version is checked by CheckWithOptions.
+type CheckOptions struct {
+	// IncludePrerelease allows versions with a pre-release to satisfy terms
+	// that do not have a pre-release, as if the constraints were parsed with
+	// ConstraintOptions.IncludePrerelease. When false, each term keeps the
+	// pre-release handling it was parsed with.
+	IncludePrerelease bool
+}
+
+// CheckWithOptions tests if a version satisfies the constraints using the
+// passed in options. This allows the same Constraints to be checked with a
+// different pre-release policy without parsing it again.
+func (cs Constraints) CheckWithOptions(v *Version, opts CheckOptions) bool {
+	// loop over the ORs and check the inner ANDs
+	for _, o := range cs.constraints {
+		joy := true
+		for _, c := range o {
+			if !c.checkWithOptions(v, opts) {
+				joy = false
+				break
+			}
+		}
+
+		if joy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckString parses a version string and tests if it satisfies the
+// constraints. An error is returned if the version can not be parsed.
+func (cs Constraints) CheckString(version string) (bool, error) {
+	v, err := NewVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	return cs.Check(v), nil
+}
+
+// HighestChannel returns the greatest version that satisfies the constraints
+// and whose pre-release channel, as returned by PrereleaseChannel, is
+// channel. False is returned if no version qualifies.
+//
+// Versions with a pre-release only satisfy constraints that have a
+// pre-release themselves. To find release candidates of the 1.3 line use a
+// constraint such as ~1.3.0-0 rather than ~1.3.0.
+func (cs Constraints) HighestChannel(versions []*Version, channel string) (*Version, bool) {
+	var h *Version
+	for _, v := range versions {
+		if v.PrereleaseChannel() != channel || !cs.Check(v) {
+			continue
+		}
+		if h == nil || v.GreaterThan(h) {
+			h = v
+		}
+	}
+
+	return h, h != nil
+}
+
 // Validate checks if a version satisfies a constraint. If not a slice of
 // reasons for the failure are returned in addition to a bool.
 func (cs Constraints) Validate(v *Version) (bool, []error) {
@@ -83,9 +454,456 @@ func (cs Constraints) Validate(v *Version) (bool, []error) {
 	return false, e
 }
 
+// FailingTerm returns the first term that the version does not satisfy. For
+// example, 2.1.0 fails the < 2.0.0 term of >= 1.2.0, < 2.0.0. A term is only
+// returned for constraints without an OR (||), as otherwise there is no single
+// term to blame. False is returned when there is an OR or the version
+// satisfies the constraints.
+//
+// Ranges such as 1.2 - 2.0 are split into their bounds, so the failing bound
+// is returned. Other operators, such as ^ and ~, are returned as written.
+//
+// A wildcard can not be written as a Term, so a term with one is returned as
+// an equivalent term without it. For example, 1.2.x is returned as ~1.2.0,
+// 1.x as ^1.0.0, and < 1.2.x as < 1.3.0. A != with a wildcard has no such
+// equivalent and is returned with the lowest version it excludes, so
+// != 1.2.x is returned as != 1.2.0 even though it excludes all of 1.2.
+//
+// The channel of a MatchChannel constraint has no version, so it can not be
+// written as a Term either. False is returned when it is the failing term.
+func (cs Constraints) FailingTerm(v *Version) (Term, bool) {
+	if len(cs.constraints) != 1 {
+		return Term{}, false
+	}
+
+	for _, c := range cs.constraints[0] {
+		if !c.check(v) {
+			if c.con == nil {
+				return Term{}, false
+			}
+			return c.term(), true
+		}
+	}
+
+	return Term{}, false
+}
+
+// PinnedVersions returns the distinct versions referenced by equality (=, or
+// no operator) terms across all of the constraints, in the order they first
+// appear. Ranges, wildcards, and other operators are ignored.
+func (cs Constraints) PinnedVersions() []*Version {
+	var vs []*Version
+	for _, o := range cs.constraints {
+		for _, c := range o {
+			if c.dirty || (c.op != "" && c.op != "=") || c.con == nil {
+				continue
+			}
+
+			dup := false
+			for _, v := range vs {
+				if v.Equal(c.con) {
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				vs = append(vs, c.con)
+			}
+		}
+	}
+
+	return vs
+}
+
+// Equivalent tests if two sets of constraints allow exactly the same
+// versions. For example, ^1.2.0 is equivalent to >= 1.2.0, < 2.0.0.
+//
+// Versions are compared by their precedence, so pre-releases are treated as
+// any other version between two releases. The rule that pre-releases only
+// satisfy constraints that have a pre-release is not considered. Constraints
+// that can not be expressed as version ranges, such as those returned by
+// MatchChannel, are never equivalent to anything.
+func (cs Constraints) Equivalent(other *Constraints) bool {
+	a, ok := cs.intervals()
+	if !ok {
+		return false
+	}
+	b, ok := other.intervals()
+	if !ok {
+		return false
+	}
+
+	return a.subset(b) && b.subset(a)
+}
+
+// Subtract returns constraints allowing the versions allowed by cs but not
+// by other. For example, ^1.0.0 minus =1.5.0 allows every 1.x.x version
+// other than 1.5.0. The result is written in terms of the >, >=, <, <=, and =
+// operators. Pre-releases are handled as described for Equivalent. Nil is
+// returned if either constraints can not be expressed as version ranges.
+func (cs Constraints) Subtract(other *Constraints) *Constraints {
+	a, ok := cs.intervals()
+	if !ok {
+		return nil
+	}
+	b, ok := other.intervals()
+	if !ok {
+		return nil
+	}
+
+	return a.intersect(b.complement()).constraints()
+}
+
+// Simplify returns constraints where OR groups whose version ranges overlap
+// or touch are merged into one. For example, >= 1.0.0, < 1.5.0 || >= 1.4.0,
+// < 2.0.0 becomes >= 1.0.0, < 2.0.0. Only groups bounded on both sides are
+// merged and a merged group is written in terms of the >, >=, <, <=, and =
+// operators. Other groups, including those with nothing to merge with, are
+// kept as they are. Pre-releases are handled as described for Equivalent.
+func (cs Constraints) Simplify() *Constraints {
+	type branch struct {
+		k int
+		i interval
+	}
+
+	var bounded []branch
+	var is intervals
+	for k, o := range cs.constraints {
+		and, ok := Constraints{constraints: [][]*constraint{o}}.intervals()
+		if !ok || len(and) != 1 || and[0].lower.v == nil || and[0].upper.v == nil {
+			continue
+		}
+		if includesPrerelease(o) {
+			continue
+		}
+		bounded = append(bounded, branch{k: k, i: and[0]})
+		is = append(is, and[0])
+	}
+
+	// Each merged range replaces the first group it was made from. Groups
+	// that were not merged with any other are kept as written.
+	replace := make(map[int][]*constraint)
+	for _, m := range is.normalize() {
+		var from []int
+		for _, b := range bounded {
+			if compareLower(m.lower, b.i.lower) <= 0 && compareUpper(b.i.upper, m.upper) <= 0 {
+				from = append(from, b.k)
+			}
+		}
+		if len(from) < 2 {
+			continue
+		}
+		replace[from[0]] = intervals{m}.constraints().constraints[0]
+		for _, k := range from[1:] {
+			replace[k] = nil
+		}
+	}
+
+	or := make([][]*constraint, 0, len(cs.constraints))
+	for k, o := range cs.constraints {
+		if r, ok := replace[k]; ok {
+			if r != nil {
+				or = append(or, r)
+			}
+			continue
+		}
+		or = append(or, o)
+	}
+
+	return &Constraints{constraints: or}
+}
+
+// includesPrerelease reports if any of the constraints allow pre-releases
+// without having a pre-release, such as with the +pre suffix.
+func includesPrerelease(cs []*constraint) bool {
+	for _, c := range cs {
+		if c.includePrerelease {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalString returns the constraints in a normalized form for use as a
+// cache key. The terms in each OR group are sorted by operator and then by
+// version, and spacing, operator aliases (e.g., => for >=), the v prefix,
+// build metadata, and the spelling of wildcards are normalized. For example,
+// "=> v1.2.X,<2.0.0" becomes "<2.0.0, >=1.2.x". The order of the OR groups
+// is kept.
+func (cs Constraints) CanonicalString() string {
+	or := make([]string, len(cs.constraints))
+	for k, o := range cs.constraints {
+		and := make([]*constraint, len(o))
+		copy(and, o)
+		sort.SliceStable(and, func(x, y int) bool {
+			a, b := canonicalOps[and[x].op], canonicalOps[and[y].op]
+			if a != b {
+				return a < b
+			}
+			if and[x].con == nil || and[y].con == nil {
+				return and[y].con != nil
+			}
+			if d := and[x].con.Compare(and[y].con); d != 0 {
+				return d < 0
+			}
+			return and[x].canonicalString() < and[y].canonicalString()
+		})
+
+		terms := make([]string, len(and))
+		for i, c := range and {
+			terms[i] = c.canonicalString()
+		}
+		or[k] = strings.Join(terms, ", ")
+	}
+
+	return strings.Join(or, " || ")
+}
+
+// CouldMatchMajor reports if any version with the major version could
+// satisfy the constraints. When false, every version with that major version
+// can be skipped without checking them one at a time. For example, ^1.0.0
+// could match the major version 1 but not 0 or 2.
+//
+// The pre-releases of the major version are only considered for OR groups
+// where every term has a pre-release or allows them, as otherwise Check
+// rejects them. True is always returned for constraints that can not be
+// expressed as version ranges, such as those returned by MatchChannel.
+func (cs Constraints) CouldMatchMajor(major uint64) bool {
+	if major > math.MaxInt64 {
+		return false
+	}
+
+	for _, o := range cs.constraints {
+		is, ok := Constraints{constraints: [][]*constraint{o}}.intervals()
+		if !ok {
+			return true
+		}
+
+		// The lowest version with a major version is its first pre-release,
+		// or the first release when pre-releases can not match.
+		lower := &Version{major: int64(major)}
+		if allowsPrerelease(o) {
+			lower.pre = "0"
+		}
+		m := interval{lower: bound{v: lower, inclusive: true}}
+		if major < math.MaxInt64 {
+			m.upper = bound{v: &Version{major: int64(major) + 1, pre: "0"}}
+		}
+
+		if len(is.intersect(intervals{m})) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsPrerelease reports if a version with a pre-release can satisfy all
+// of the constraints. That is, each of them has a pre-release, includes
+// pre-releases, or is a != without a wildcard, which does not apply the
+// pre-release rule.
+func allowsPrerelease(cs []*constraint) bool {
+	for _, c := range cs {
+		if c.op == "!=" && !c.dirty {
+			continue
+		}
+		if !c.includePrerelease && c.con != nil && c.con.pre == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Widen returns constraints where each exact version term (e.g., =1.2.3 or
+// 1.2.3) is replaced by a range starting from it. The level is one of:
+//
+//	caret: ^1.2.3, any version from 1.2.3 below 2.0.0
+//	tilde: ~1.2.3, any version from 1.2.3 below 1.3.0
+//	minor: 1.2.x, any version in the 1.2 minor version, including those
+//	       before 1.2.3
+//
+// Other terms, including wildcards such as =1.2.x, are kept. An error is
+// returned for an unknown level.
+func (cs Constraints) Widen(level string) (*Constraints, error) {
+	if level != "caret" && level != "tilde" && level != "minor" {
+		return nil, fmt.Errorf("unknown level '%s' to widen constraint", level)
+	}
+
+	or := make([][]*constraint, len(cs.constraints))
+	for k, o := range cs.constraints {
+		and := make([]*constraint, len(o))
+		for i, c := range o {
+			and[i] = c
+			if c.con == nil || c.dirty || (c.op != "" && c.op != "=") {
+				continue
+			}
+
+			var w *constraint
+			switch level {
+			case "caret":
+				w = newConstraint("^", c.con)
+			case "tilde":
+				w = newConstraint("~", c.con)
+			case "minor":
+				w, _ = parseConstraint(fmt.Sprintf("=%d.%d.x", c.con.major, c.con.minor), ConstraintOptions{})
+			}
+			w.includePrerelease = c.includePrerelease
+			and[i] = w
+		}
+		or[k] = and
+	}
+
+	return &Constraints{constraints: or}, nil
+}
+
+// CountVersions returns the number of releases the constraints allow at a
+// granularity of "patch" (each distinct x.y.z) or "minor" (each distinct x.y).
+// For example, ~1.2.3 allows the 1.2 minor version only. Versions with a
+// pre-release are not counted and a missing lower bound starts at 0.0.0.
+//
+// As version segments are limited to math.MaxInt64, a constraint such as
+// ~1.2.0 allows every patch from 1.2.0 through 1.2.9223372036854775807.
+// A count that does not fit in a uint64, such as the patches of ^1.0.0, is
+// returned as math.MaxUint64. False is returned when there is no upper bound
+// or the granularity is not known.
+func (cs Constraints) CountVersions(granularity string) (uint64, bool) {
+	if granularity != "patch" && granularity != "minor" {
+		return 0, false
+	}
+
+	is, ok := cs.intervals()
+	if !ok {
+		return 0, false
+	}
+
+	total := new(big.Int)
+	var last *big.Int
+	for _, i := range is {
+		if i.upper.v == nil {
+			return 0, false
+		}
+		lo, hi, ok := i.releases()
+		if !ok {
+			continue
+		}
+
+		l, h := releaseIndex(lo, granularity), releaseIndex(hi, granularity)
+
+		// Disjoint intervals can still share a minor version (e.g., !=1.2.5
+		// within 1.2). Only count it once.
+		if last != nil && l.Cmp(last) == 0 {
+			l.Add(l, big.NewInt(1))
+		}
+		last = h
+
+		if l.Cmp(h) <= 0 {
+			n := new(big.Int).Sub(h, l)
+			total.Add(total, n.Add(n, big.NewInt(1)))
+		}
+	}
+
+	if !total.IsUint64() {
+		return math.MaxUint64, true
+	}
+	return total.Uint64(), true
+}
+
+// releaseIndex gives each release an index so the number of releases between
+// two of them is the difference of their indexes. The granularity is "patch"
+// or "minor", as for CountVersions.
+func releaseIndex(v *Version, granularity string) *big.Int {
+	seg := new(big.Int).SetUint64(math.MaxInt64 + 1)
+	i := new(big.Int).Mul(big.NewInt(v.major), seg)
+	i.Add(i, big.NewInt(v.minor))
+	if granularity == "patch" {
+		i.Mul(i, seg)
+		i.Add(i, big.NewInt(v.patch))
+	}
+	return i
+}
+
+// Headroom returns the number of patch releases above the version that are
+// still allowed by the first OR group the version satisfies, up to that
+// group's upper bound. For example, 1.2.3 with >= 1.2.0, < 1.2.7 has the
+// releases 1.2.4 through 1.2.6 remaining. For a version with a pre-release
+// the release it leads up to is also counted.
+//
+// When the upper bound is past the version's minor version, as it is for
+// 1.2.3 with ~1.2.0 or ^1.0.0, patch releases do not limit the version and
+// math.MaxUint64 is returned. False is returned when the version does not
+// satisfy the constraints or the group has no upper bound.
+func (cs Constraints) Headroom(v *Version) (uint64, bool) {
+	for _, o := range cs.constraints {
+		and := Constraints{constraints: [][]*constraint{o}}
+		if !and.Check(v) {
+			continue
+		}
+
+		is, ok := and.intervals()
+		if !ok {
+			return 0, false
+		}
+		for _, i := range is {
+			if !(intervals{i}).contains(v) {
+				continue
+			}
+			u := i.upper.v
+			if u == nil {
+				return 0, false
+			}
+			if u.major != v.major || u.minor != v.minor {
+				return math.MaxUint64, true
+			}
+
+			// Only pre-releases are left below the bound.
+			_, hi, ok := i.releases()
+			if !ok {
+				return 0, true
+			}
+
+			n := hi.patch - v.patch
+			if v.pre != "" {
+				n++
+			}
+			if n < 0 {
+				return 0, true
+			}
+			return uint64(n), true
+		}
+		return 0, false
+	}
+
+	return 0, false
+}
+
+// MaxSatisfying returns the greatest version, without a pre-release, that
+// the constraints allow. False is returned when there is no upper bound or
+// no such version.
+//
+// An exclusive upper bound has the smallest possible increment removed. As
+// version segments are limited to math.MaxInt64 the greatest version below
+// 1.3.0, such as for ~1.2.0, is 1.2.9223372036854775807 and the greatest
+// below 2.0.0, such as for ^1.0.0, is 1.9223372036854775807.9223372036854775807.
+func (cs Constraints) MaxSatisfying() (*Version, bool) {
+	is, ok := cs.intervals()
+	if !ok || len(is) == 0 || is[len(is)-1].upper.v == nil {
+		return nil, false
+	}
+
+	for k := len(is) - 1; k >= 0; k-- {
+		if _, hi, ok := is[k].releases(); ok {
+			hi.original = hi.String()
+			return hi, true
+		}
+	}
+
+	return nil, false
+}
+
 var constraintOps map[string]cfunc
 var constraintMsg map[string]string
 var constraintRegex *regexp.Regexp
+var constraintOpRegex *regexp.Regexp
 
 func init() {
 	constraintOps = map[string]cfunc{
@@ -128,9 +946,48 @@ func init() {
 		strings.Join(ops, "|"),
 		cvRegex))
 
+	constraintOpRegex = regexp.MustCompile(`^\s*([<>=!~^]+)`)
+
+	// The operators are required in adjacent terms, so the empty operator
+	// is left out.
+	termOps := make([]string, 0, len(ops))
+	for _, o := range ops {
+		if o != "" {
+			termOps = append(termOps, o)
+		}
+	}
+	constraintAdjacentRegex = regexp.MustCompile(fmt.Sprintf(
+		`^\s*(?:(?:%s)\s*%s){2,}\s*$`,
+		strings.Join(termOps, "|"),
+		cvRegex))
+	constraintAdjacentTermRegex = regexp.MustCompile(fmt.Sprintf(
+		`(?:%s)\s*%s`,
+		strings.Join(termOps, "|"),
+		cvRegex))
+
 	constraintRangeRegex = regexp.MustCompile(fmt.Sprintf(
 		`\s*(%s)\s+-\s+(%s)\s*`,
 		cvRegex, cvRegex))
+	constraintRustRangeRegex = regexp.MustCompile(fmt.Sprintf(
+		`\s*(%s)\.\.(=?)(%s)\s*`,
+		cvRegex, cvRegex))
+	constraintVersionRegex = regexp.MustCompile("^" + cvRegex + "$")
+}
+
+// canonicalOps maps each operator to the one written by CanonicalString.
+var canonicalOps = map[string]string{
+	"":   "=",
+	"=":  "=",
+	"!=": "!=",
+	">":  ">",
+	"<":  "<",
+	">=": ">=",
+	"=>": ">=",
+	"<=": "<=",
+	"=<": "<=",
+	"~":  "~",
+	"~>": "~",
+	"^":  "^",
 }
 
 // An individual constraint
@@ -139,6 +996,9 @@ type constraint struct {
 	// the constraint.
 	function cfunc
 
+	// The operator as written in the constraint (e.g., >=).
+	op string
+
 	msg string
 
 	// The version used in the constraint check. For example, if a constraint
@@ -152,6 +1012,10 @@ type constraint struct {
 	minorDirty bool
 	dirty      bool
 	patchDirty bool
+
+	// When versions with a pre-release are checked the same as any other
+	// version. See ConstraintOptions.IncludePrerelease.
+	includePrerelease bool
 }
 
 // Check if a version meets the constraint
@@ -159,14 +1023,90 @@ func (c *constraint) check(v *Version) bool {
 	return c.function(v, c)
 }
 
+// Check if a version meets the constraint with the options applied. The
+// options are applied to a copy so the constraint itself is not changed.
+func (c *constraint) checkWithOptions(v *Version, opts CheckOptions) bool {
+	if opts.IncludePrerelease && !c.includePrerelease {
+		cc := *c
+		cc.includePrerelease = true
+		return cc.check(v)
+	}
+	return c.check(v)
+}
+
 type cfunc func(v *Version, c *constraint) bool
 
-func parseConstraint(c string) (*constraint, error) {
+// term returns the constraint as a Term. A wildcard is replaced by an
+// equivalent operator where there is one, as described for FailingTerm.
+func (c *constraint) term() Term {
+	if !c.dirty || c.con == nil {
+		return Term{Operator: c.op, Version: c.con}
+	}
+
+	majorDirty := !c.minorDirty && !c.patchDirty
+	switch c.op {
+	case "", "=", "~", "~>":
+		if majorDirty {
+			return Term{Operator: ">=", Version: c.con}
+		}
+		if c.minorDirty {
+			return Term{Operator: "^", Version: c.con}
+		}
+		return Term{Operator: "~", Version: c.con}
+	case "<", "<=", "=<":
+		return Term{Operator: "<", Version: c.wildcardUpper().v}
+	}
+
+	return Term{Operator: c.op, Version: c.con}
+}
+
+// canonicalString returns the constraint as written by CanonicalString.
+func (c *constraint) canonicalString() string {
+	if c.con == nil {
+		return "channel:" + c.orig
+	}
+
+	v := c.con.StringWithoutMetadata()
+	if c.dirty {
+		pre := ""
+		if c.con.pre != "" {
+			pre = "-" + c.con.pre
+		}
+
+		switch {
+		case c.minorDirty:
+			v = fmt.Sprintf("%d.x%s", c.con.major, pre)
+		case c.patchDirty:
+			v = fmt.Sprintf("%d.%d.x%s", c.con.major, c.con.minor, pre)
+		default:
+			v = "*" + pre
+		}
+	}
+
+	if c.includePrerelease {
+		v += "+pre"
+	}
+	return canonicalOps[c.op] + v
+}
+
+func parseConstraint(c string, opts ConstraintOptions) (*constraint, error) {
 	m := constraintRegex.FindStringSubmatch(c)
 	if m == nil {
+
+		// Point out a mistyped operator (e.g., >>1.0.0) rather than reporting
+		// the whole constraint as improper.
+		if o := constraintOpRegex.FindStringSubmatch(c); o != nil {
+			if _, ok := constraintOps[o[1]]; !ok {
+				return nil, fmt.Errorf("unknown operator '%s' in constraint: %s", o[1], c)
+			}
+		}
 		return nil, fmt.Errorf("improper constraint: %s", c)
 	}
 
+	if opts.DisallowOperatorAliases && (m[1] == "=>" || m[1] == "=<") {
+		return nil, fmt.Errorf("unknown operator '%s' in constraint: %s", m[1], c)
+	}
+
 	ver := m[2]
 	orig := ver
 	minorDirty := false
@@ -183,6 +1123,25 @@ func parseConstraint(c string) (*constraint, error) {
 		dirty = true
 		patchDirty = true
 		ver = fmt.Sprintf("%s%s.0%s", m[3], m[4], m[6])
+	} else if opts.PartialAsWildcard && m[5] == "" && (m[1] == "" || m[1] == "=") {
+
+		// A missing patch on an equality constraint is handled the same way
+		// as an x in the patch position (e.g., 1.2 is treated as 1.2.x).
+		dirty = true
+		patchDirty = true
+	}
+
+	// A +pre suffix opts a single term in to matching pre-releases. For the
+	// operators that match a whole line of releases (e.g., 1.4.x or ~1.4)
+	// the pre-releases of the first release in the line are included too.
+	includePrerelease := opts.IncludePrerelease
+	if m[10] == "pre" {
+		includePrerelease = true
+		ver = strings.TrimSuffix(ver, "+pre")
+		if m[7] == "" && (dirty && (m[1] == "" || m[1] == "=") ||
+			m[1] == "~" || m[1] == "~>" || m[1] == "^") {
+			ver += "-0"
+		}
 	}
 
 	con, err := NewVersion(ver)
@@ -195,16 +1154,91 @@ func parseConstraint(c string) (*constraint, error) {
 
 	cs := &constraint{
 		function:   constraintOps[m[1]],
+		op:         m[1],
 		msg:        constraintMsg[m[1]],
 		con:        con,
 		orig:       orig,
 		minorDirty: minorDirty,
 		patchDirty: patchDirty,
 		dirty:      dirty,
+
+		includePrerelease: includePrerelease,
 	}
 	return cs, nil
 }
 
+// newConstraint creates a constraint for an operator and a complete version
+// without parsing a string.
+func newConstraint(op string, v *Version) *constraint {
+	return &constraint{
+		function: constraintOps[op],
+		op:       op,
+		msg:      constraintMsg[op],
+		con:      v,
+		orig:     v.String(),
+	}
+}
+
+// parseTerm parses a single term of a constraint. A range is rewritten into
+// its two bounds, so more than one constraint may be returned.
+func parseTerm(s string, opts ConstraintOptions, strict bool) ([]*constraint, error) {
+	if strict {
+		if err := checkStrictConstraint(s); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, f := range rewriteFuncs {
+			s = f(s)
+		}
+	}
+
+	var r []*constraint
+	for _, t := range strings.Split(s, ",") {
+		pc, err := parseConstraint(t, opts)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, pc)
+	}
+	return r, nil
+}
+
+// checkStrictConstraint returns an error if a single constraint uses any of
+// the shorthands NewStrictConstraint forbids.
+func checkStrictConstraint(c string) error {
+	if constraintRangeRegex.MatchString(c) {
+		return fmt.Errorf("hyphen range not allowed in strict constraint: %s", c)
+	}
+	if constraintRustRangeRegex.MatchString(c) {
+		return fmt.Errorf("range not allowed in strict constraint: %s", c)
+	}
+
+	m := constraintRegex.FindStringSubmatch(c)
+	if m == nil {
+		// Leave reporting the error to parseConstraint.
+		return nil
+	}
+
+	switch m[1] {
+	case "^", "~", "~>":
+		return fmt.Errorf("operator '%s' not allowed in strict constraint: %s", m[1], c)
+	}
+
+	if isX(m[3]) || isX(strings.TrimPrefix(m[4], ".")) || isX(strings.TrimPrefix(m[5], ".")) {
+		return fmt.Errorf("wildcard not allowed in strict constraint: %s", c)
+	}
+
+	if m[4] == "" || m[5] == "" {
+		return fmt.Errorf("partial version not allowed in strict constraint: %s", c)
+	}
+
+	if m[1] == "" {
+		return fmt.Errorf("missing operator in strict constraint: %s", c)
+	}
+
+	return nil
+}
+
 // Constraint functions
 func constraintNotEqual(v *Version, c *constraint) bool {
 	if c.dirty {
@@ -212,17 +1246,23 @@ func constraintNotEqual(v *Version, c *constraint) bool {
 		// If there is a pre-release on the version but the constraint isn't looking
 		// for them assume that pre-releases are not compatible. See issue 21 for
 		// more details.
-		if v.Prerelease() != "" && c.con.Prerelease() == "" {
+		if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
+			return false
+		}
+
+		// A wildcard in the major position (e.g., != *) excludes every
+		// version.
+		if !c.minorDirty && !c.patchDirty {
 			return false
 		}
 
+		// Otherwise every version sharing the non-wildcard prefix is
+		// excluded. For example, != 1.2.x excludes all of 1.2.*.
 		if c.con.Major() != v.Major() {
 			return true
 		}
 		if c.con.Minor() != v.Minor() && !c.minorDirty {
 			return true
-		} else if c.minorDirty {
-			return false
 		}
 
 		return false
@@ -236,7 +1276,7 @@ func constraintGreaterThan(v *Version, c *constraint) bool {
 	// If there is a pre-release on the version but the constraint isn't looking
 	// for them assume that pre-releases are not compatible. See issue 21 for
 	// more details.
-	if v.Prerelease() != "" && c.con.Prerelease() == "" {
+	if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
 		return false
 	}
 
@@ -247,7 +1287,7 @@ func constraintLessThan(v *Version, c *constraint) bool {
 	// If there is a pre-release on the version but the constraint isn't looking
 	// for them assume that pre-releases are not compatible. See issue 21 for
 	// more details.
-	if v.Prerelease() != "" && c.con.Prerelease() == "" {
+	if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
 		return false
 	}
 
@@ -269,7 +1309,7 @@ func constraintGreaterThanEqual(v *Version, c *constraint) bool {
 	// If there is a pre-release on the version but the constraint isn't looking
 	// for them assume that pre-releases are not compatible. See issue 21 for
 	// more details.
-	if v.Prerelease() != "" && c.con.Prerelease() == "" {
+	if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
 		return false
 	}
 
@@ -280,7 +1320,7 @@ func constraintLessThanEqual(v *Version, c *constraint) bool {
 	// If there is a pre-release on the version but the constraint isn't looking
 	// for them assume that pre-releases are not compatible. See issue 21 for
 	// more details.
-	if v.Prerelease() != "" && c.con.Prerelease() == "" {
+	if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
 		return false
 	}
 
@@ -297,6 +1337,12 @@ func constraintLessThanEqual(v *Version, c *constraint) bool {
 	return true
 }
 
+// The channel is stored as the original value as there is no version to
+// compare against.
+func constraintChannel(v *Version, c *constraint) bool {
+	return v.PrereleaseChannel() == c.orig
+}
+
 // ~*, ~>* --> >= 0.0.0 (any)
 // ~2, ~2.x, ~2.x.x, ~>2, ~>2.x ~>2.x.x --> >=2.0.0, <3.0.0
 // ~2.0, ~2.0.x, ~>2.0, ~>2.0.x --> >=2.0.0, <2.1.0
@@ -307,7 +1353,7 @@ func constraintTilde(v *Version, c *constraint) bool {
 	// If there is a pre-release on the version but the constraint isn't looking
 	// for them assume that pre-releases are not compatible. See issue 21 for
 	// more details.
-	if v.Prerelease() != "" && c.con.Prerelease() == "" {
+	if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
 		return false
 	}
 
@@ -339,12 +1385,13 @@ func constraintTildeOrEqual(v *Version, c *constraint) bool {
 	// If there is a pre-release on the version but the constraint isn't looking
 	// for them assume that pre-releases are not compatible. See issue 21 for
 	// more details.
-	if v.Prerelease() != "" && c.con.Prerelease() == "" {
+	if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
 		return false
 	}
 
 	if c.dirty {
 		c.msg = constraintMsg["~"]
+
 		return constraintTilde(v, c)
 	}
 
@@ -361,7 +1408,7 @@ func constraintCaret(v *Version, c *constraint) bool {
 	// If there is a pre-release on the version but the constraint isn't looking
 	// for them assume that pre-releases are not compatible. See issue 21 for
 	// more details.
-	if v.Prerelease() != "" && c.con.Prerelease() == "" {
+	if v.Prerelease() != "" && c.con.Prerelease() == "" && !c.includePrerelease {
 		return false
 	}
 
@@ -377,11 +1424,64 @@ func constraintCaret(v *Version, c *constraint) bool {
 }
 
 var constraintRangeRegex *regexp.Regexp
+var constraintRustRangeRegex *regexp.Regexp
+var constraintAdjacentRegex *regexp.Regexp
+var constraintAdjacentTermRegex *regexp.Regexp
+var constraintVersionRegex *regexp.Regexp
+
+// rewriteFuncs rewrite shorthand ranges in a term into terms using the basic
+// comparison operators. They are applied in order.
+var rewriteFuncs = []func(string) string{
+	rewriteRange,
+	rewriteRustRange,
+	rewriteAdjacentTerms,
+}
 
-const cvRegex string = `v?([0-9|x|X|\*]+)(\.[0-9|x|X|\*]+)?(\.[0-9|x|X|\*]+)?` +
+// cvRegex matches a version in a constraint. Each of the major, minor, and
+// patch positions is either a number or a single x, X, or * wildcard, which
+// isX recognizes. Mixing digits and wildcards (e.g., 1x) is not a version.
+const cvRegex string = `v?([0-9]+|[xX\*])(\.(?:[0-9]+|[xX\*]))?(\.(?:[0-9]+|[xX\*]))?` +
 	`(-([0-9A-Za-z\-]+(\.[0-9A-Za-z\-]+)*))?` +
 	`(\+([0-9A-Za-z\-]+(\.[0-9A-Za-z\-]+)*))?`
 
+// rewriteAdjacentTerms splits terms written one after the other without a
+// comma (e.g., >=1.2.0<2.0.0) into separate terms that must all be satisfied
+// (e.g., >=1.2.0, <2.0.0).
+//
+// A version can not contain any of the operator characters, so a term ends
+// where the next operator starts. As operators can be more than one
+// character, each term takes the operator that lets a version follow it.
+// For example, <=1.0.0 is the single term <= 1.0.0 and not < followed by
+// =1.0.0. Every term must have an operator and there must be no space
+// between a version and the next operator.
+func rewriteAdjacentTerms(i string) string {
+	parts := strings.Split(i, ",")
+	for k, p := range parts {
+		if constraintAdjacentRegex.MatchString(p) {
+			parts[k] = strings.Join(constraintAdjacentTermRegex.FindAllString(p, -1), ", ")
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// countOperators returns the number of operators in a term, which is the
+// most terms rewriteAdjacentTerms can split it into. An operator may be more
+// than one character (e.g., >=), so each run of operator characters is one
+// operator.
+func countOperators(s string) int {
+	n := 0
+	in := false
+	for i := 0; i < len(s); i++ {
+		op := strings.IndexByte("<>=!~^", s[i]) >= 0
+		if op && !in {
+			n++
+		}
+		in = op
+	}
+	return n
+}
+
+// isX reports if a version segment is a wildcard.
 func isX(x string) bool {
 	switch x {
 	case "x", "*", "X":
@@ -392,15 +1492,91 @@ func isX(x string) bool {
 }
 
 func rewriteRange(i string) string {
-	m := constraintRangeRegex.FindAllStringSubmatch(i, -1)
+	m := constraintRangeRegex.FindAllStringSubmatchIndex(i, -1)
 	if m == nil {
 		return i
 	}
-	o := i
+
+	// Each range is replaced at the position it was found. Searching for the
+	// text of a match could find the same text elsewhere, such as in an
+	// earlier range that has already been rewritten.
+	var o bytes.Buffer
+	last := 0
 	for _, v := range m {
-		t := fmt.Sprintf(">= %s, <= %s", v[1], v[11])
-		o = strings.Replace(o, v[0], t, 1)
+		o.WriteString(i[last:v[0]])
+		fmt.Fprintf(&o, "%s, %s", rangeBound(">=", i[v[2]:v[3]]), rangeBound("<=", i[v[22]:v[23]]))
+		last = v[1]
 	}
+	o.WriteString(i[last:])
 
-	return o
+	return o.String()
+}
+
+// rewriteRustRange rewrites the Rust style ranges 1.2.0..2.0.0, which
+// excludes its upper bound, and 1.2.0..=2.0.0, which includes it.
+func rewriteRustRange(i string) string {
+	m := constraintRustRangeRegex.FindAllStringSubmatchIndex(i, -1)
+	if m == nil {
+		return i
+	}
+
+	var o bytes.Buffer
+	last := 0
+	for _, v := range m {
+		o.WriteString(i[last:v[0]])
+		fmt.Fprintf(&o, "%s, %s", rangeBound(">=", i[v[2]:v[3]]), rangeBound("<"+i[v[22]:v[23]], i[v[24]:v[25]]))
+		last = v[1]
+	}
+	o.WriteString(i[last:])
+
+	return o.String()
+}
+
+// rangeBound returns the term for one bound of a range, with op being >=
+// for the lower bound and < or <= for the upper bound. The version is
+// written out in full with a missing or wildcard position filled in with a
+// 0. A partial or wildcard version as an inclusive upper bound includes
+// every release it covers instead, so <= 3 becomes < 4.0.0 and <= 3.1.x
+// becomes < 3.2.0. The pre-releases of the next release are kept out with
+// a -0 when the bound allows pre-releases. A wildcard major version, such
+// as *, has no version to write out and is left as it is.
+func rangeBound(op, ver string) string {
+	m := constraintVersionRegex.FindStringSubmatch(ver)
+	if m == nil || isX(m[1]) {
+		return op + " " + ver
+	}
+
+	major := m[1]
+	minor := strings.TrimPrefix(m[2], ".")
+	patch := strings.TrimPrefix(m[3], ".")
+	if minor == "" || isX(minor) {
+		minor, patch = "x", "x"
+	}
+
+	if op == "<=" && isX(patch) {
+
+		// The version has been matched by cvRegex so the numbers are valid.
+		next := &Version{}
+		next.major, _ = strconv.ParseInt(major, 10, 64)
+		if isX(minor) {
+			next.major++
+		} else {
+			next.minor, _ = strconv.ParseInt(minor, 10, 64)
+			next.minor++
+		}
+
+		pre := ""
+		if m[4] != "" || m[8] == "pre" {
+			pre = "-0"
+		}
+		return fmt.Sprintf("< %s%s%s", next, pre, m[7])
+	}
+
+	if isX(minor) {
+		minor = "0"
+	}
+	if patch == "" || isX(patch) {
+		patch = "0"
+	}
+	return fmt.Sprintf("%s %s.%s.%s%s%s", op, major, minor, patch, m[4], m[7])
 }
@@ -1,7 +1,9 @@
 package semver
 
 import (
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -25,7 +27,7 @@ func TestParseConstraint(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		c, err := parseConstraint(tc.in)
+		c, err := parseConstraint(tc.in, ConstraintOptions{})
 		if tc.err && err == nil {
 			t.Errorf("Expected error for %s didn't occur", tc.in)
 		} else if !tc.err && err != nil {
@@ -50,6 +52,144 @@ func TestParseConstraint(t *testing.T) {
 	}
 }
 
+func TestParseConstraintWildcards(t *testing.T) {
+	tests := []struct {
+		in         string
+		v          string
+		minorDirty bool
+		patchDirty bool
+		err        bool
+	}{
+		{"1.x", "1.0.0", true, false, false},
+		{"1.X", "1.0.0", true, false, false},
+		{"1.*", "1.0.0", true, false, false},
+		{"1.x.X", "1.0.0", true, false, false},
+		{"1.X.*", "1.0.0", true, false, false},
+		{"1.2.x", "1.2.0", false, true, false},
+		{"1.2.X", "1.2.0", false, true, false},
+		{"1.2.*", "1.2.0", false, true, false},
+		{"x", "0.0.0", false, false, false},
+		{"X.x.*", "0.0.0", false, false, false},
+		{"*.X", "0.0.0", false, false, false},
+		{">= 1.2.X", "1.2.0", false, true, false},
+		{"~ 1.X", "1.0.0", true, false, false},
+		{"1.xx", "", false, false, true},
+		{"1.2.X*", "", false, false, true},
+		{"1.2x", "", false, false, true},
+		{"1.x2", "", false, false, true},
+		{"1|2.0", "", false, false, true},
+	}
+
+	for _, tc := range tests {
+		c, err := parseConstraint(tc.in, ConstraintOptions{})
+		if tc.err {
+			if err == nil {
+				t.Errorf("Expected error for %s didn't occur", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", tc.in, err)
+			continue
+		}
+
+		if tc.v != c.con.String() {
+			t.Errorf("Incorrect version found on %s: %s", tc.in, c.con)
+		}
+		if !c.dirty || c.minorDirty != tc.minorDirty || c.patchDirty != tc.patchDirty {
+			t.Errorf("Incorrect wildcard found on %s", tc.in)
+		}
+	}
+
+	// Each wildcard matches the same versions in every position.
+	for _, w := range []string{"x", "X", "*"} {
+		for _, c := range []string{"1.X", "1.2.*", "1.x.X", "1.2.X - 1.4.*", ">=1.X, <3.*"} {
+			lower, err := NewConstraint(strings.NewReplacer("x", "x", "X", "x", "*", "x").Replace(c))
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			other, err := NewConstraint(strings.NewReplacer("X", w, "*", w, "x", w).Replace(c))
+			if err != nil {
+				t.Errorf("err: %s", err)
+				continue
+			}
+
+			for _, v := range []string{"0.9.0", "1.0.0", "1.2.0", "1.2.9", "1.3.0", "1.4.5", "2.0.0", "3.1.0", "4.0.0"} {
+				if e, a := lower.Check(MustParse(v)), other.Check(MustParse(v)); e != a {
+					t.Errorf("Constraint %q with %s wildcards and %q: expected %t but got %t", c, w, v, e, a)
+				}
+			}
+		}
+	}
+}
+
+func TestParseConstraintUnknownOperator(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{">>1.0.0", "unknown operator '>>' in constraint: >>1.0.0"},
+		{"=>=1.0.0", "unknown operator '=>=' in constraint: =>=1.0.0"},
+		{" <> 1.0.0", "unknown operator '<>' in constraint:  <> 1.0.0"},
+		{"!1.0.0", "unknown operator '!' in constraint: !1.0.0"},
+		{"~~1.0.0", "unknown operator '~~' in constraint: ~~1.0.0"},
+		{">= foo", "improper constraint: >= foo"},
+		{"foo", "improper constraint: foo"},
+	}
+
+	for _, tc := range tests {
+		_, err := parseConstraint(tc.in, ConstraintOptions{})
+		if err == nil {
+			t.Errorf("Expected error for %s didn't occur", tc.in)
+			continue
+		}
+
+		if err.Error() != tc.err {
+			t.Errorf("Expected error %q for %s but got %q", tc.err, tc.in, err)
+		}
+	}
+
+	for op := range constraintOps {
+		in := op + "1.0.0"
+		if _, err := parseConstraint(in, ConstraintOptions{}); err != nil {
+			t.Errorf("Unexpected error for %s: %s", in, err)
+		}
+	}
+}
+
+func TestNewConstraintDisallowOperatorAliases(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{"=>1.0.0", "unknown operator '=>' in constraint: =>1.0.0"},
+		{"=< 2.0.0", "unknown operator '=<' in constraint: =< 2.0.0"},
+		{">=1.0.0, =<2.0.0", "unknown operator '=<' in constraint: =<2.0.0"},
+		{">=1.0.0", ""},
+		{"<= 2.0.0", ""},
+		{"~>1.2.0", ""},
+	}
+
+	opts := ConstraintOptions{DisallowOperatorAliases: true}
+	for _, tc := range tests {
+		_, err := NewConstraintWithOptions(tc.in, opts)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for %s: %s", tc.in, err)
+			}
+		} else if err == nil {
+			t.Errorf("Expected error for %s didn't occur", tc.in)
+		} else if err.Error() != tc.err {
+			t.Errorf("Expected error %q for %s but got %q", tc.err, tc.in, err)
+		}
+
+		// The aliases are allowed by default.
+		if _, err := NewConstraint(tc.in); err != nil {
+			t.Errorf("Unexpected error for %s: %s", tc.in, err)
+		}
+	}
+}
+
 func TestConstraintCheck(t *testing.T) {
 	tests := []struct {
 		constraint string
@@ -92,7 +232,7 @@ func TestConstraintCheck(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		c, err := parseConstraint(tc.constraint)
+		c, err := parseConstraint(tc.constraint, ConstraintOptions{})
 		if err != nil {
 			t.Errorf("err: %s", err)
 			continue
@@ -156,6 +296,423 @@ func TestNewConstraint(t *testing.T) {
 	}
 }
 
+func TestNewConstraintWhitespace(t *testing.T) {
+	tests := []struct {
+		input   string
+		version string
+		check   bool
+		err     string
+	}{
+		{">= 1.2.0 ,  < 2.0.0", "1.5.0", true, ""},
+		{">= 1.2.0 ,  < 2.0.0", "2.0.0", false, ""},
+		{"  >=1.2.0,<2.0.0  ", "1.5.0", true, ""},
+		{"\t>= 1.2.0\t,\n< 2.0.0 ", "1.5.0", true, ""},
+		{" ^1.0.0 ||  ^3.0.0 , != 3.1.0 ", "3.1.0", false, ""},
+		{" ^1.0.0 ||  ^3.0.0 , != 3.1.0 ", "3.2.0", true, ""},
+		{" 1.0.0  -  2.0.0 , != 1.5.0", "1.4.0", true, ""},
+		{">= 1.2.0, , < 2.0.0", "", false, "empty term in constraint: >= 1.2.0, , < 2.0.0"},
+		{">= 1.2.0,", "", false, "empty term in constraint: >= 1.2.0,"},
+		{">= 1.2.0 || ", "", false, "empty term in constraint: >= 1.2.0 || "},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.input)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("Expected error %q for %q but got %v", tc.err, tc.input, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("unexpected error for input %q: %s", tc.input, err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := c.Check(v); a != tc.check {
+			t.Errorf("Constraint %q failing with '%s'", tc.input, tc.version)
+		}
+	}
+}
+
+func TestNewConstraintEmpty(t *testing.T) {
+	tests := []struct {
+		version string
+		include bool
+		check   bool
+	}{
+		{"1.2.3", false, true},
+		{"0.0.0", false, true},
+		{"v10.20.30+build.1", false, true},
+		{"1.2.3-beta", false, false},
+		{"1.2.3-beta", true, true},
+	}
+
+	for _, input := range []string{"", " ", "\t \n"} {
+		for _, tc := range tests {
+			opts := ConstraintOptions{IncludePrerelease: tc.include}
+			c, err := NewConstraintWithOptions(input, opts)
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", input, err)
+				continue
+			}
+
+			if a := c.Check(MustParse(tc.version)); a != tc.check {
+				t.Errorf("Constraint %q (include: %t) failing with '%s'",
+					input, tc.include, tc.version)
+			}
+		}
+
+		if _, err := NewStrictConstraint(input); err == nil {
+			t.Errorf("expected but did not get error for strict constraint %q", input)
+		}
+	}
+}
+
+func TestConstraintParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		term  string
+		or    int
+		and   int
+		msg   string
+	}{
+		{">= 1.0.0, < 2.0.0 || >= 3.0.0, < foo", "< foo", 1, 1, "improper constraint: < foo"},
+		{"1.0 - 2.0, foo || ^3", "foo", 0, 1, "improper constraint: foo"},
+		{"^1 || ^2 || 3.0.0 - 4.0.0, >> 3.5.0", ">> 3.5.0", 2, 1,
+			"unknown operator '>>' in constraint: >> 3.5.0"},
+		{"^1 ||  , ^2", "", 1, 0, "empty term in constraint: ^1 ||  , ^2"},
+	}
+
+	for _, tc := range tests {
+		_, err := NewConstraint(tc.input)
+		e, ok := err.(*ConstraintParseError)
+		if !ok {
+			t.Errorf("Expected a ConstraintParseError for %q but got %v", tc.input, err)
+			continue
+		}
+
+		if e.Term != tc.term || e.Or != tc.or || e.And != tc.and {
+			t.Errorf("Expected %q to fail on %q at %d, %d but got %q at %d, %d",
+				tc.input, tc.term, tc.or, tc.and, e.Term, e.Or, e.And)
+		}
+		if e.Error() != tc.msg {
+			t.Errorf("Expected error %q for %q but got %q", tc.msg, tc.input, e)
+		}
+	}
+
+	_, err := NewStrictConstraint(">=1.0.0 || >=2.0.0, ^3.0.0")
+	if e, ok := err.(*ConstraintParseError); !ok || e.Or != 1 || e.And != 1 || e.Term != "^3.0.0" {
+		t.Errorf("Expected strict error at 1, 1 for ^3.0.0 but got %#v", err)
+	}
+}
+
+func TestNewConstraintMaxTerms(t *testing.T) {
+	tests := []struct {
+		input string
+		max   int
+		err   bool
+	}{
+		{">=1.0.0, <2.0.0 || ^3", 3, false},
+		{">=1.0.0, <2.0.0 || ^3", 2, true},
+		{">=1.0.0, <2.0.0 || ^3", 0, false},
+		{">=1.0.0, <2.0.0 || ^3", -1, false},
+		{strings.Repeat("1.0.0 || ", DefaultMaxTerms) + "1.0.0", -1, true},
+		{strings.Repeat("1.0.0 || ", DefaultMaxTerms-1) + "1.0.0", 0, false},
+		{strings.Repeat("1.0.0 || ", DefaultMaxTerms) + "1.0.0", 0, true},
+		{strings.Repeat("1.0.0, ", DefaultMaxTerms) + "1.0.0", DefaultMaxTerms + 1, false},
+		{">=1.0.0<2.0.0!=1.5.0", 3, false},
+		{">=1.0.0<2.0.0!=1.5.0", 2, true},
+		{">=1.0.0<2.0.0, ^1.2.0", 2, true},
+		{strings.Repeat(">1", 5000), 10, true},
+	}
+
+	for _, tc := range tests {
+		_, err := NewConstraintWithOptions(tc.input, ConstraintOptions{MaxTerms: tc.max})
+		if tc.err && err == nil {
+			t.Errorf("expected but did not get error for %d byte input with limit %d",
+				len(tc.input), tc.max)
+		} else if !tc.err && err != nil {
+			t.Errorf("unexpected error for %d byte input with limit %d: %s",
+				len(tc.input), tc.max, err)
+		}
+	}
+
+	// Rejecting an enormous constraint should not allocate per term.
+	huge := strings.Repeat(">=1.0.0, <2.0.0 || ", 100000) + "1.0.0"
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := NewConstraint(huge); err == nil {
+			t.Error("expected but did not get error for huge constraint")
+		}
+	})
+	if allocs > 10 {
+		t.Errorf("Rejecting a huge constraint made %.0f allocations", allocs)
+	}
+}
+
+func TestNewConstraintAndSeparator(t *testing.T) {
+	tests := []struct {
+		input   string
+		sep     string
+		version string
+		check   bool
+		err     bool
+	}{
+		{">=1.0.0 && <2.0.0", "&&", "1.5.0", true, false},
+		{">=1.0.0 && <2.0.0", "&&", "2.0.0", false, false},
+		{">=1.0.0&&<2.0.0 || ^3 && !=3.1.0", "&&", "3.1.0", false, false},
+		{">=1.0.0&&<2.0.0 || ^3 && !=3.1.0", "&&", "3.2.0", true, false},
+		{"1.0.0 - 2.0.0 && !=1.5.0", "&&", "1.4.0", true, false},
+		{"1.0.0 - 2.0.0 && !=1.5.0", "&&", "1.5.0", false, false},
+		{">=1.0.0; <2.0.0", ";", "1.5.0", true, false},
+		{">=1.0.0 & <2.0.0 || 3.0.0", "&", "3.0.0", true, false},
+		{">=1.0.0, <2.0.0", "", "1.5.0", true, false},
+		{">=1.0.0, <2.0.0", ",", "1.5.0", true, false},
+		{">=1.0.0, <2.0.0", "&&", "", false, true},
+		{">=1.0.0 && <2.0.0", "", "", false, true},
+		{">=1.0.0 && && <2.0.0", "&&", "", false, true},
+		{">=1.0.0 | <2.0.0 || 3.0.0", "|", "", false, true},
+		{"1.0.0 - 2.0.0", "-", "", false, true},
+		{">=1.0.0 <2.0.0", " ", "", false, true},
+		{">=1.0.0.<2.0.0", ".", "", false, true},
+		{">=1.0.0 and <2.0.0", "and", "", false, true},
+		{">=1.0.0 0 <2.0.0", "0", "", false, true},
+		{">=1.0.0 ^ <2.0.0", "^", "", false, true},
+		{">=1.0.0 + <2.0.0", "+", "", false, true},
+		{">=1.0.0 ,, <2.0.0", ",,", "", false, true},
+	}
+
+	for _, tc := range tests {
+		opts := ConstraintOptions{AndSeparator: tc.sep}
+		c, err := NewConstraintWithOptions(tc.input, opts)
+		if tc.err {
+			if err == nil {
+				t.Errorf("expected but did not get error for %q with separator %q", tc.input, tc.sep)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("unexpected error for %q with separator %q: %s", tc.input, tc.sep, err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := c.Check(v); a != tc.check {
+			t.Errorf("Constraint %q failing with '%s'", tc.input, tc.version)
+		}
+	}
+}
+
+func TestNewConstraintFromTerms(t *testing.T) {
+	c, err := NewConstraintFromTerms([][]Term{
+		{{">=", MustParse("1.2.0")}, {"<", MustParse("2.0.0")}, {"!=", MustParse("1.5.0")}},
+		{{"~", MustParse("3.1.0")}},
+		{{"", MustParse("4.0.0-rc.1")}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p, err := NewConstraint(">=1.2.0, <2.0.0, !=1.5.0 || ~3.1.0 || 4.0.0-rc.1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, r := range []string{
+		"1.1.9", "1.2.0", "1.4.9", "1.5.0", "1.5.1", "1.9.9-beta", "2.0.0",
+		"3.0.9", "3.1.0", "3.1.9", "3.2.0", "4.0.0-rc.1", "4.0.0",
+	} {
+		v := MustParse(r)
+		if a, e := c.Check(v), p.Check(v); a != e {
+			t.Errorf("Expected terms to check %s as %t but got %t", r, e, a)
+		}
+	}
+
+	errs := [][][]Term{
+		nil,
+		{{}},
+		{{{">=", MustParse("1.0.0")}}, {}},
+		{{{">>", MustParse("1.0.0")}}},
+		{{{">=", nil}}},
+	}
+
+	for _, tc := range errs {
+		if _, err := NewConstraintFromTerms(tc); err == nil {
+			t.Errorf("expected but did not get error for terms %v", tc)
+		}
+	}
+}
+
+func TestMinimalConstraint(t *testing.T) {
+	tests := []struct {
+		versions []string
+		expected string
+		inside   []string
+		outside  []string
+	}{
+		{
+			[]string{"1.5.0", "1.2.0", "1.8.3", "1.3.1"},
+			">=1.2.0, <=1.8.3",
+			[]string{"1.2.1", "1.7.9"},
+			[]string{"1.1.9", "1.8.4", "1.9.0", "2.0.0"},
+		},
+		{
+			[]string{"0.9.0", "2.1.0"},
+			">=0.9.0, <=2.1.0",
+			[]string{"1.0.0"},
+			[]string{"0.8.9", "2.1.1"},
+		},
+		{
+			[]string{"1.2.3", "v1.2.3"},
+			"=1.2.3",
+			nil,
+			[]string{"1.2.2", "1.2.4"},
+		},
+		{
+			[]string{"1.0.0-beta.1", "1.2.0"},
+			">=1.0.0-beta.1, <=1.2.0",
+			[]string{"1.0.0-beta.2", "1.1.0-rc.1"},
+			[]string{"1.0.0-alpha", "1.2.1-rc.1"},
+		},
+	}
+
+	for _, tc := range tests {
+		var versions []*Version
+		for _, s := range tc.versions {
+			versions = append(versions, MustParse(s))
+		}
+
+		c, err := MinimalConstraint(versions)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		var terms []string
+		for _, c := range c.constraints[0] {
+			terms = append(terms, c.op+c.orig)
+		}
+		if a := strings.Join(terms, ", "); a != tc.expected {
+			t.Errorf("Expected constraint %q but got %q", tc.expected, a)
+		}
+		for _, s := range append(tc.versions, tc.inside...) {
+			if !c.Check(MustParse(s)) {
+				t.Errorf("Expected %q to satisfy %q", s, tc.expected)
+			}
+		}
+		for _, s := range tc.outside {
+			if c.Check(MustParse(s)) {
+				t.Errorf("Expected %q not to satisfy %q", s, tc.expected)
+			}
+		}
+	}
+
+	if _, err := MinimalConstraint(nil); err == nil {
+		t.Error("Expected error for no versions")
+	}
+	if _, err := MinimalConstraint([]*Version{MustParse("1.0.0"), nil}); err == nil {
+		t.Error("Expected error for nil version")
+	}
+}
+
+func TestMinimalVersionSatisfying(t *testing.T) {
+	candidates := []*Version{
+		MustParse("1.5.0"),
+		MustParse("1.2.0"),
+		MustParse("2.1.0"),
+		MustParse("1.3.0-beta.1"),
+		MustParse("1.3.1"),
+		MustParse("1.4.0"),
+		MustParse("0.9.0"),
+	}
+
+	tests := []struct {
+		constraints []string
+		expected    string
+		ok          bool
+	}{
+		{[]string{"^1.0.0", ">=1.3.0"}, "1.3.1", true},
+		{[]string{">=1.2.0, <2.0.0", "~1.4.0 || ~1.5.0"}, "1.4.0", true},
+		{[]string{"^1.0.0", "!=1.2.0", "!=1.3.1"}, "1.4.0", true},
+		{[]string{">=1.3.0-alpha", "<1.4.0-0"}, "1.3.0-beta.1", true},
+		{[]string{">=1.3.0-alpha", "<1.4.0"}, "1.3.1", true},
+		{[]string{"^1.0.0"}, "1.2.0", true},
+		{nil, "0.9.0", true},
+		{[]string{"^1.0.0", ">=2.0.0"}, "", false},
+		{[]string{"~1.3.0", "<1.3.1"}, "", false},
+	}
+
+	for _, tc := range tests {
+		var css []*Constraints
+		for _, s := range tc.constraints {
+			c, err := NewConstraint(s)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			css = append(css, c)
+		}
+
+		v, ok := MinimalVersionSatisfying(css, candidates)
+		if ok != tc.ok {
+			t.Errorf("Constraints %q: expected ok %t but got %t", tc.constraints, tc.ok, ok)
+			continue
+		}
+		if ok && v.String() != tc.expected {
+			t.Errorf("Constraints %q: expected %s but got %s", tc.constraints, tc.expected, v)
+		}
+	}
+
+	if _, ok := MinimalVersionSatisfying(nil, nil); ok {
+		t.Error("Expected no version without candidates")
+	}
+}
+
+func TestNewStrictConstraint(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{">=1.0.0, <2.0.0", ""},
+		{">= 1.0.0, < 2.0.0 || =3.0.0-rc.1 || =4.0.0 || !=5.0.0", ""},
+		{"^1.0.0", "operator '^' not allowed in strict constraint: ^1.0.0"},
+		{"~1.0.0", "operator '~' not allowed in strict constraint: ~1.0.0"},
+		{"~>1.0.0", "operator '~>' not allowed in strict constraint: ~>1.0.0"},
+		{"1.x", "wildcard not allowed in strict constraint: 1.x"},
+		{">=1.2.*", "wildcard not allowed in strict constraint: >=1.2.*"},
+		{"*", "wildcard not allowed in strict constraint: *"},
+		{"1.0.0 - 2.0.0", "hyphen range not allowed in strict constraint: 1.0.0 - 2.0.0"},
+		{">=1.0.0, <2", "partial version not allowed in strict constraint: <2"},
+		{">=1.0", "partial version not allowed in strict constraint: >=1.0"},
+		{">=foo", "improper constraint: >=foo"},
+		{"4.0.0", "missing operator in strict constraint: 4.0.0"},
+		{">=1.0.0, 1.5.0", "missing operator in strict constraint: 1.5.0"},
+	}
+
+	for _, tc := range tests {
+		c, err := NewStrictConstraint(tc.input)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", tc.input, err)
+			} else if !c.Check(MustParse("1.5.0")) {
+				t.Errorf("Constraint %q failing with 1.5.0", tc.input)
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("Expected error %q for %q but got %v", tc.err, tc.input, err)
+		}
+	}
+}
+
 func TestConstraintsCheck(t *testing.T) {
 	tests := []struct {
 		constraint string
@@ -238,6 +795,8 @@ func TestConstraintsCheck(t *testing.T) {
 		{"~1.2.3", "1.3.2", false},
 		{"~1.1", "1.2.3", false},
 		{"~1.3", "2.4.5", false},
+		{"^2.0.0", "v2.3.0+incompatible", true},
+		{"=2.0.0", "v2.0.0+incompatible", true},
 	}
 
 	for _, tc := range tests {
@@ -260,23 +819,1329 @@ func TestConstraintsCheck(t *testing.T) {
 	}
 }
 
-func TestRewriteRange(t *testing.T) {
+func TestConstraintsBareMajor(t *testing.T) {
 	tests := []struct {
-		c  string
-		nc string
+		constraint string
+		version    string
+		check      bool
 	}{
-		{"2 - 3", ">= 2, <= 3"},
-		{"2 - 3, 2 - 3", ">= 2, <= 3,>= 2, <= 3"},
-		{"2 - 3, 4.0.0 - 5.1", ">= 2, <= 3,>= 4.0.0, <= 5.1"},
+		{"1", "1.0.0", true},
+		{"1", "1.9.9", true},
+		{"1", "1.9.9-beta", false},
+		{"1", "0.9.9", false},
+		{"1", "2.0.0", false},
+		{"=1", "1.4.2", true},
+		{"v1", "1.4.2", true},
+		{"2", "2.0.0", true},
+		{"2", "2.10.3", true},
+		{"2", "1.10.3", false},
+		{"2", "3.0.0", false},
+		{"0", "0.0.0", true},
+		{"0", "0.0.1", true},
+		{"0", "0.99.0", true},
+		{"0", "1.0.0", false},
+		{"1-beta", "1.0.0-alpha", false},
+		{"1-beta", "1.0.0-beta.1", true},
+		{"1-beta", "1.5.0", true},
 	}
 
 	for _, tc := range tests {
-		o := rewriteRange(tc.c)
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
 
-		if o != tc.nc {
-			t.Errorf("Range %s rewritten incorrectly as '%s'", tc.c, o)
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		a := c.Check(v)
+		if a != tc.check {
+			t.Errorf("Constraint '%s' failing with '%s'", tc.constraint, tc.version)
 		}
 	}
+
+	c, _ := NewConstraintWithOptions("1", ConstraintOptions{IncludePrerelease: true})
+	if c.Check(MustParse("1.0.0-beta")) {
+		t.Error("Constraint '1' including pre-releases should not match 1.0.0-beta")
+	}
+	if !c.Check(MustParse("1.0.1-beta")) {
+		t.Error("Constraint '1' including pre-releases should match 1.0.1-beta")
+	}
+}
+
+func TestConstraintsMixedPrefix(t *testing.T) {
+	tests := []struct {
+		mixed string
+		bare  string
+	}{
+		{">=v1.2.0, <2.0.0", ">=1.2.0, <2.0.0"},
+		{">=1.2.0, <v2.0.0", ">=1.2.0, <2.0.0"},
+		{"v1.2.0 - 2.0.0", "1.2.0 - 2.0.0"},
+		{"1.2.0 - v2.0.0", "1.2.0 - 2.0.0"},
+		{"v1.2.0..2.0.0", "1.2.0..2.0.0"},
+		{"^v1.2.0 || ~2.1.0", "^1.2.0 || ~2.1.0"},
+		{"!=v1.5.x, >=1.0.0", "!=1.5.x, >=1.0.0"},
+		{"v1.x, <=v1.8.0-beta", "1.x, <=1.8.0-beta"},
+	}
+
+	versions := []string{
+		"0.9.0", "1.0.0", "1.2.0", "v1.2.0", "1.5.0", "v1.5.3",
+		"1.8.0-alpha", "1.8.0", "2.0.0", "v2.0.0", "2.1.5", "3.0.0",
+	}
+
+	for _, tc := range tests {
+		mixed, err := NewConstraint(tc.mixed)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+		bare, err := NewConstraint(tc.bare)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		for _, s := range versions {
+			v := MustParse(s)
+			if e, a := bare.Check(v), mixed.Check(v); e != a {
+				t.Errorf("Constraint %q with %q: expected %t as for %q but got %t", tc.mixed, s, e, tc.bare, a)
+			}
+		}
+		if !mixed.Equivalent(bare) {
+			t.Errorf("Constraint %q is not equivalent to %q", tc.mixed, tc.bare)
+		}
+	}
+
+	c, err := NewConstraint(">=v1.2.0, <2.0.0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !c.Check(MustParse("1.5.0")) {
+		t.Error("Expected 1.5.0 to satisfy >=v1.2.0, <2.0.0")
+	}
+}
+
+func TestConstraintsPartialAsWildcard(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		wildcard   bool
+		check      bool
+	}{
+		{"1.2", "1.2.0", false, true},
+		{"1.2", "1.2.9", false, false},
+		{"=1.2", "1.2.9", false, false},
+		{"1.2", "1.2.0", true, true},
+		{"1.2", "1.2.9", true, true},
+		{"=1.2", "1.2.9", true, true},
+		{"1.2", "1.3.0", true, false},
+		{"1.2", "1.1.9", true, false},
+		{"1.2.3", "1.2.9", true, false},
+		{"<1.2", "1.2.5", true, false},
+		{"1.2", "1.2.1-beta", true, false},
+	}
+
+	for _, tc := range tests {
+		opts := ConstraintOptions{PartialAsWildcard: tc.wildcard}
+		c, err := NewConstraintWithOptions(tc.constraint, opts)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		a := c.Check(v)
+		if a != tc.check {
+			t.Errorf("Constraint '%s' (wildcard: %t) failing with '%s'",
+				tc.constraint, tc.wildcard, tc.version)
+		}
+	}
+}
+
+func TestConstraintsNotEqual(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		check      bool
+	}{
+		{">=1.0.0, !=1.5.0, <2.0.0", "1.5.0", false},
+		{">=1.0.0, !=1.5.0, <2.0.0", "1.4.9", true},
+		{">=1.0.0, !=1.5.0, <2.0.0", "1.5.1", true},
+		{">=1.0.0, !=1.5.0, <2.0.0", "2.0.0", false},
+		{"!=1.2.x", "1.2.0", false},
+		{"!=1.2.x", "1.2.9", false},
+		{"!=1.2.x", "1.2.100", false},
+		{"!=1.2.x", "1.1.9", true},
+		{"!=1.2.x", "1.3.0", true},
+		{"!=1.x", "1.0.0", false},
+		{"!=1.x", "1.9.9", false},
+		{"!=1.x", "2.0.0", true},
+		{"!=*", "0.0.0", false},
+		{"!=*", "1.2.3", false},
+		{"!= *", "0.0.0", false},
+		{"!= *", "0.9.0", false},
+		{"!= *", "2.0.0", false},
+		{"!= x", "3.1.4", false},
+		{"!= X.x", "10.0.0", false},
+		{">=1.0.0, !=1.2.x, <2.0.0", "1.2.5", false},
+		{">=1.0.0, !=1.2.x, <2.0.0", "1.3.0", true},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		a := c.Check(v)
+		if a != tc.check {
+			t.Errorf("Constraint '%s' failing with '%s'", tc.constraint, tc.version)
+		}
+	}
+}
+
+func TestConstraintsIncludePrerelease(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		include    bool
+		check      bool
+	}{
+		{"1.0.0 - 2.0.0", "1.5.0-rc1", false, false},
+		{"1.0.0 - 2.0.0", "1.5.0-rc1", true, true},
+		{"1.0.0 - 2.0.0", "1.5.0", false, true},
+		{"1.0.0 - 2.0.0", "1.5.0", true, true},
+		{"1.0.0 - 2.0.0", "2.0.0-rc1", true, true},
+		{"1.0.0 - 2.0.0", "2.0.1-rc1", true, false},
+		{"1.0.0 - 2.0.0", "1.0.0-rc1", true, false},
+		{"1.0 - 2 || 3.0.0 - 4.0.0", "3.5.0-beta", true, true},
+		{"^1.2.0", "1.3.0-beta", true, true},
+		{"~1.2", "1.2.5-beta", true, true},
+		{"1.2.x", "1.2.5-beta", true, true},
+		{"!=1.2.3", "1.2.4-beta", true, true},
+		{"<1.2.3", "1.2.3-beta", true, true},
+		{"<1.2.3", "1.2.3-beta", false, false},
+	}
+
+	for _, tc := range tests {
+		opts := ConstraintOptions{IncludePrerelease: tc.include}
+		c, err := NewConstraintWithOptions(tc.constraint, opts)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		a := c.Check(v)
+		if a != tc.check {
+			t.Errorf("Constraint '%s' (include: %t) failing with '%s'",
+				tc.constraint, tc.include, tc.version)
+		}
+	}
+}
+
+func TestConstraintsTermPrerelease(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		check      bool
+	}{
+		{"1.4.x+pre", "1.4.2", true},
+		{"1.4.x+pre", "1.4.0-rc1", true},
+		{"1.4.x+pre", "1.4.3-beta.2", true},
+		{"1.4.x+pre", "1.5.0", false},
+		{"1.4.x+pre", "1.5.0-rc1", false},
+		{"1.4.x+pre", "1.3.9", false},
+		{"1.4.x", "1.4.0-rc1", false},
+		{"=1.4.x+pre", "1.4.0-rc1", true},
+		{"~1.4+pre", "1.4.0-rc1", true},
+		{"~1.4+pre", "1.5.0-rc1", false},
+		{"^1.4.0+pre", "1.9.0-beta", true},
+		{"^1.4.0+pre", "1.4.0-beta", true},
+		{"1.4.0+pre", "1.4.0", true},
+		{"1.4.0+pre", "1.4.0-rc1", false},
+		{"!=1.4.x+pre", "1.4.0-rc1", false},
+		{"!=1.4.x+pre", "1.5.0-rc1", true},
+		{">1.4.0+pre", "1.4.0", false},
+		{">1.4.0+pre", "1.4.1-rc1", true},
+		{">=1.4.0+pre, <1.5.0", "1.4.1-rc1", false},
+		{">=1.4.0+pre, <1.5.0+pre", "1.4.1-rc1", true},
+		{"1.4.x+pre || 2.x", "1.4.0-rc1", true},
+		{"1.4.x+pre || 2.x", "2.1.0-rc1", false},
+		{"1.4.x+build", "1.4.0-rc1", false},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		a := c.Check(v)
+		if a != tc.check {
+			t.Errorf("Constraint '%s' failing with '%s'", tc.constraint, tc.version)
+		}
+	}
+}
+
+func TestConstraintsCheckBatch(t *testing.T) {
+	constraints := []string{
+		"*",
+		"1.2.3",
+		"= 2.0",
+		"1.x",
+		"!=1.2.x",
+		"!=*",
+		">1.2.3",
+		">2.x",
+		"<2.0.0",
+		"<=1.2.x",
+		">=1.1.0, <2.0.0-beta",
+		"~1.2.3",
+		"~1",
+		"~*",
+		"^1.2.0",
+		"^0.2.3",
+		"^*",
+		"1.0.0 - 2.0.0",
+		"1.1..=1.4",
+		"~1.0.0 || ~1.2.0 || >=3.0.0",
+		">=1.0.0, <1.5.0 || >=1.4.0, <2.0.0",
+		">3.0.0, <3.0.0",
+		">=1.0.0-alpha",
+	}
+
+	var versions []*Version
+	for _, s := range []string{
+		"0.0.0", "0.2.3", "0.2.9", "0.9.0", "1.0.0", "1.0.5", "1.1.0", "1.2.0",
+		"1.2.3", "1.2.4+build", "1.3.0", "1.4.9", "1.5.0", "2.0.0-beta", "2.0.0",
+		"2.0.1", "2.1.0", "3.0.0", "3.0.0-rc.1", "v4.2.0", "1.0.0-beta.2",
+	} {
+		versions = append(versions, MustParse(s))
+	}
+
+	for _, c := range constraints {
+		cs, err := NewConstraint(c)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		r := cs.CheckBatch(versions)
+		if len(r) != len(versions) {
+			t.Errorf("Constraint %q: expected %d results but got %d", c, len(versions), len(r))
+			continue
+		}
+		for k, v := range versions {
+			if e := cs.Check(v); r[k] != e {
+				t.Errorf("Constraint %q with %q: expected %t but got %t", c, v, e, r[k])
+			}
+		}
+	}
+
+	r := MatchChannel("beta").CheckBatch(versions)
+	for k, v := range versions {
+		if e := v.PrereleaseChannel() == "beta"; r[k] != e {
+			t.Errorf("Channel beta with %q: expected %t but got %t", v, e, r[k])
+		}
+	}
+}
+
+func TestConstraintsCheckWithOptions(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		strict     bool
+		loose      bool
+	}{
+		{"^1.2.0", "1.3.0-beta", false, true},
+		{"^1.2.0", "1.3.0", true, true},
+		{"^1.2.0", "2.0.0-beta", false, false},
+		{"1.0.0 - 2.0.0", "1.5.0-rc1", false, true},
+		{">=1.2.0-0", "1.3.0-beta", true, true},
+		{"1.4.x+pre", "1.4.0-rc1", true, true},
+		{"~1.2 || ^3", "3.1.0-rc.1", false, true},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		// Check the same Constraints with both policies, and again to be
+		// sure neither changed it.
+		for i := 0; i < 2; i++ {
+			if a := c.CheckWithOptions(v, CheckOptions{}); a != tc.strict {
+				t.Errorf("Constraint '%s' failing with '%s'", tc.constraint, tc.version)
+			}
+			if a := c.CheckWithOptions(v, CheckOptions{IncludePrerelease: true}); a != tc.loose {
+				t.Errorf("Constraint '%s' including pre-releases failing with '%s'",
+					tc.constraint, tc.version)
+			}
+			if a := c.Check(v); a != tc.strict {
+				t.Errorf("Constraint '%s' failing with '%s' after CheckWithOptions",
+					tc.constraint, tc.version)
+			}
+		}
+	}
+}
+
+func TestConstraintsCheckString(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		check      bool
+		err        bool
+	}{
+		{"^1.2", "1.4.0", true, false},
+		{"^1.2", "v1.4.0", true, false},
+		{"^1.2", "2.0.0", false, false},
+		{"^1.2", "1.2.beta", false, true},
+		{"^1.2", "", false, true},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		a, err := c.CheckString(tc.version)
+		if tc.err && err == nil {
+			t.Errorf("Expected error for version %q didn't occur", tc.version)
+		} else if !tc.err && err != nil {
+			t.Errorf("Unexpected error for version %q: %s", tc.version, err)
+		}
+
+		if a != tc.check {
+			t.Errorf("Constraint '%s' failing with '%s'", tc.constraint, tc.version)
+		}
+	}
+}
+
+func TestMatchChannel(t *testing.T) {
+	tests := []struct {
+		channel string
+		version string
+		check   bool
+	}{
+		{"internal", "1.2.3-internal+sha.deadbeef.branch.main", true},
+		{"internal", "1.2.3-internal.2", true},
+		{"internal", "1.2.3-internal-2", false},
+		{"internal", "1.2.3-rc.1", false},
+		{"internal", "1.2.3+internal", false},
+		{"internal", "1.2.3", false},
+		{"rc", "2.0.0-rc.1", true},
+		{"", "1.2.3", true},
+		{"", "1.2.3-rc.1", false},
+	}
+
+	for _, tc := range tests {
+		c := MatchChannel(tc.channel)
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		a := c.Check(v)
+		if a != tc.check {
+			t.Errorf("Channel %q failing with '%s'", tc.channel, tc.version)
+		}
+
+		ok, msgs := c.Validate(v)
+		if ok != tc.check || (!ok && len(msgs) != 1) {
+			t.Errorf("Channel %q failed to validate '%s'", tc.channel, tc.version)
+		}
+	}
+}
+
+func TestConstraintsFailingTerm(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		op         string
+		term       string
+		ok         bool
+	}{
+		{"^1.0.0", "2.0.0", "^", "1.0.0", true},
+		{"^1.0.0", "1.5.0", "", "", false},
+		{">= 1.0.0, < 2.0.0", "2.0.0", "<", "2.0.0", true},
+		{">= 1.0.0, < 2.0.0", "0.9.0", ">=", "1.0.0", true},
+		{"^1.0.0, != 1.2.0", "1.2.0", "!=", "1.2.0", true},
+		{">1.0.0, <3.0.0, !=2.0.0", "4.0.0", "<", "3.0.0", true},
+		{"1.0 - 2.0", "2.1.0", "<=", "2.0.0", true},
+		{"1.2.3", "1.2.4", "", "1.2.3", true},
+		{"1.2.x", "1.3.0", "~", "1.2.0", true},
+		{"=1.2.X", "1.1.0", "~", "1.2.0", true},
+		{"1.x", "2.0.0", "^", "1.0.0", true},
+		{"1", "2.0.0", "^", "1.0.0", true},
+		{"~1.x", "2.0.0", "^", "1.0.0", true},
+		{"~1.2.x", "1.3.0", "~", "1.2.0", true},
+		{"*", "1.0.0-beta", ">=", "0.0.0", true},
+		{"^1.2.x", "2.0.0", "^", "1.2.0", true},
+		{">=1.2.x", "1.1.0", ">=", "1.2.0", true},
+		{"<1.2.x", "1.3.0", "<", "1.3.0", true},
+		{"<=1.x", "2.0.0", "<", "2.0.0", true},
+		{"!=1.2.x", "1.2.5", "!=", "1.2.0", true},
+		{"^1.0.0 || ^3.0.0", "2.0.0", "", "", false},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		term, ok := c.FailingTerm(MustParse(tc.version))
+		if ok != tc.ok {
+			t.Errorf("Constraint %q with %q: expected ok %t but got %t", tc.constraint, tc.version, tc.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if term.Operator != tc.op || term.Version.String() != tc.term {
+			t.Errorf("Constraint %q with %q: expected term %s%s but got %s%s", tc.constraint, tc.version, tc.op, tc.term, term.Operator, term.Version)
+			continue
+		}
+
+		// A term without a wildcard rebuilds into a constraint that the
+		// version fails in the same way.
+		if term.Operator == "!=" {
+			continue
+		}
+		r, err := NewConstraintFromTerms([][]Term{{term}})
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+		if r.Check(MustParse(tc.version)) {
+			t.Errorf("Constraint %q with %q: term %s%s does not fail", tc.constraint, tc.version, term.Operator, term.Version)
+		}
+	}
+
+	// A channel has no version to return as a term.
+	c := MatchChannel("beta")
+	if term, ok := c.FailingTerm(MustParse("1.0.0-alpha.1")); ok || term.Version != nil {
+		t.Errorf("Expected no failing term for channel but got %s%s", term.Operator, term.Version)
+	}
+	if _, ok := c.FailingTerm(MustParse("1.0.0-beta.1")); ok {
+		t.Error("Expected no failing term for a version in the channel")
+	}
+}
+
+func TestConstraintsPinnedVersions(t *testing.T) {
+	tests := []struct {
+		constraint string
+		pinned     []string
+	}{
+		{"=1.0.0 || =2.0.0", []string{"1.0.0", "2.0.0"}},
+		{"1.0.0 || =2.0.0 || 1.0.0", []string{"1.0.0", "2.0.0"}},
+		{"^1.0.0", nil},
+		{"~1.0.0 || 1.x || 2.3.x || *", nil},
+		{"1.0.0 - 2.0.0", nil},
+		{">=1.0.0, !=1.5.0, <2.0.0", nil},
+		{"=1.2.3-beta.1 || >3", []string{"1.2.3-beta.1"}},
+		{"v1.2 || >=2, <3 || 3.1.4", []string{"1.2.0", "3.1.4"}},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		var a []string
+		for _, v := range c.PinnedVersions() {
+			a = append(a, v.String())
+		}
+
+		if !reflect.DeepEqual(a, tc.pinned) {
+			t.Errorf("Expected %q to pin %v but got %v", tc.constraint, tc.pinned, a)
+		}
+	}
+}
+
+func TestConstraintsEquivalent(t *testing.T) {
+	tests := []struct {
+		a, b       string
+		equivalent bool
+	}{
+		{"^1.2.0", ">=1.2.0, <2.0.0", true},
+		{"^1.2.0", "~1.2.0", false},
+		{"~1.2.0", ">=1.2.0, <1.3.0", true},
+		{"~1.2.3", "1.2.3 - 1.3.0, !=1.3.0", true},
+		{"1.2.x", ">=1.2.0, <1.3.0", true},
+		{"!=1.2.x", "<1.2.0 || >=1.3.0", true},
+		{"!=1.5.0, >=1.0.0, <2.0.0", ">=1.0.0, <1.5.0 || >1.5.0, <2.0.0", true},
+		{"<1.5.0 || >=1.4.0, <2.0.0", "<2.0.0", true},
+		{">=1.0.0, <1.5.0 || >=1.5.0, <2.0.0", "^1", true},
+		{">=1.0.0, <1.5.0 || >1.5.0, <2.0.0", "^1", false},
+		{"*", ">=0.0.0", true},
+		{">2.0.0, <1.0.0", ">3.0.0, <3.0.0", true},
+		{"=1.2.3", "1.2.3", true},
+		{"=1.2.3", ">=1.2.3, <=1.2.3", true},
+		{">1.2.3", ">=1.2.3", false},
+	}
+
+	for _, tc := range tests {
+		a, err := NewConstraint(tc.a)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		b, err := NewConstraint(tc.b)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if e := a.Equivalent(b); e != tc.equivalent {
+			t.Errorf("Expected %q equivalent to %q to be %t", tc.a, tc.b, tc.equivalent)
+		}
+		if e := b.Equivalent(a); e != tc.equivalent {
+			t.Errorf("Expected %q equivalent to %q to be %t", tc.b, tc.a, tc.equivalent)
+		}
+	}
+
+	c, _ := NewConstraint("*")
+	if MatchChannel("rc").Equivalent(MatchChannel("rc")) || c.Equivalent(MatchChannel("rc")) {
+		t.Error("Channel constraints should not be equivalent to anything")
+	}
+}
+
+func TestConstraintsHighestChannel(t *testing.T) {
+	raw := []string{
+		"1.2.9-rc.1",
+		"1.3.0-alpha.1",
+		"1.3.0-alpha.2",
+		"1.3.0-beta.1",
+		"1.3.0-rc.1",
+		"1.3.0-rc.2",
+		"1.3.0",
+		"1.3.1-rc.1",
+		"1.3.1-beta.3",
+		"1.4.0-rc.1",
+	}
+
+	vs := make([]*Version, len(raw))
+	for i, r := range raw {
+		v, err := NewVersion(r)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		vs[i] = v
+	}
+
+	tests := []struct {
+		constraint string
+		channel    string
+		expected   string
+	}{
+		{"~1.3.0-0", "rc", "1.3.1-rc.1"},
+		{"~1.3.0-0", "beta", "1.3.1-beta.3"},
+		{"~1.3.0-0", "alpha", "1.3.0-alpha.2"},
+		{"~1.3.0-0", "", "1.3.0"},
+		{">=1.3.0-0, <1.3.1-0", "rc", "1.3.0-rc.2"},
+		{"~1.3.0-0", "dev", ""},
+		{"~1.3.0", "rc", ""},
+		{"^1.0.0-0", "rc", "1.4.0-rc.1"},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, ok := c.HighestChannel(vs, tc.channel)
+		if tc.expected == "" {
+			if ok || v != nil {
+				t.Errorf("Expected no %q version for %q but got %s", tc.channel, tc.constraint, v)
+			}
+			continue
+		}
+
+		if !ok || v.String() != tc.expected {
+			t.Errorf("Expected %s as the highest %q version for %q but got %s",
+				tc.expected, tc.channel, tc.constraint, v)
+		}
+	}
+}
+
+func TestConstraintsSubtract(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		version  string
+		check    bool
+		expected string
+	}{
+		{"^1.0.0", "=1.5.0", "1.0.0", true, ">=1.0.0, <1.5.0 || >1.5.0, <2.0.0"},
+		{"^1.0.0", "=1.5.0", "1.4.9", true, ""},
+		{"^1.0.0", "=1.5.0", "1.5.0", false, ""},
+		{"^1.0.0", "=1.5.0", "1.5.1", true, ""},
+		{"^1.0.0", "=1.5.0", "1.9.9", true, ""},
+		{"^1.0.0", "=1.5.0", "2.0.0", false, ""},
+		{"^1.0.0", "=1.5.0", "0.9.0", false, ""},
+		{"^1.0.0", "~1.2", "1.2.5", false, ">=1.0.0, <1.2.0 || >=1.3.0, <2.0.0"},
+		{"^1.0.0", "~1.2", "1.3.0", true, ""},
+		{">=1.0.0", ">=2.0.0", "1.9.0", true, ">=1.0.0, <2.0.0"},
+		{"*", ">=1.0.0", "0.9.0", true, ">=0.0.0, <1.0.0"},
+		{"^1.0.0", "*", "1.2.0", false, ""},
+		{"^1.0.0", "<1.0.0 || >=2.0.0", "1.2.0", true, ">=1.0.0, <2.0.0"},
+		{"=1.2.3 || =1.2.4", "=1.2.4", "1.2.3", true, "=1.2.3"},
+	}
+
+	for _, tc := range tests {
+		a, err := NewConstraint(tc.a)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		b, err := NewConstraint(tc.b)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		d := a.Subtract(b)
+		if a := d.Check(v); a != tc.check {
+			t.Errorf("%q minus %q failing with '%s'", tc.a, tc.b, tc.version)
+		}
+
+		if tc.expected == "" {
+			continue
+		}
+		e, err := NewConstraint(tc.expected)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+		if !d.Equivalent(e) {
+			t.Errorf("Expected %q minus %q to be equivalent to %q", tc.a, tc.b, tc.expected)
+		}
+	}
+
+	c, _ := NewConstraint("^1.0.0")
+	if c.Subtract(MatchChannel("rc")) != nil {
+		t.Error("Expected subtracting a channel to return nil")
+	}
+}
+
+func TestConstraintsSimplify(t *testing.T) {
+	tests := []struct {
+		constraint string
+		expected   string
+		groups     int
+	}{
+		// Overlapping
+		{">=1.0.0, <1.5.0 || >=1.4.0, <2.0.0", ">=1.0.0, <2.0.0", 1},
+		{"~1.2.0 || ^1.0.0", "^1.0.0", 1},
+		{"1.0.0 - 1.6.0 || 1.2.0 - 1.4.0 || 1.5.0 - 2.0.0", ">=1.0.0, <=2.0.0", 1},
+
+		// Adjacent
+		{">=1.0.0, <1.5.0 || >=1.5.0, <2.0.0", ">=1.0.0, <2.0.0", 1},
+		{"~1.2.0 || ~1.3.0", ">=1.2.0, <1.4.0", 1},
+		{">=1.0.0, <=1.5.0 || >1.5.0, <2.0.0", ">=1.0.0, <2.0.0", 1},
+
+		// Disjoint
+		{">=1.0.0, <1.5.0 || >1.5.0, <2.0.0", ">=1.0.0, <1.5.0 || >1.5.0, <2.0.0", 2},
+		{"~1.2.0 || ~1.4.0", "~1.2.0 || ~1.4.0", 2},
+
+		// Unbounded and mixed
+		{">=3.0.0 || >=1.0.0, <2.0.0", ">=3.0.0 || >=1.0.0, <2.0.0", 2},
+		{"<1.0.0 || ^1.0.0", "<1.0.0 || ^1.0.0", 2},
+		{"~1.2.0 || >=5.0.0 || ~1.3.0", ">=1.2.0, <1.4.0 || >=5.0.0", 2},
+		{"^1.0.0", "^1.0.0", 1},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+		e, err := NewConstraint(tc.expected)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		s := c.Simplify()
+		if !s.Equivalent(e) {
+			t.Errorf("Expected %q to simplify to %q", tc.constraint, tc.expected)
+		}
+		if a := len(s.constraints); a != tc.groups {
+			t.Errorf("Expected %q to simplify to %d groups but got %d", tc.constraint, tc.groups, a)
+		}
+	}
+
+	// Groups with nothing to merge with are kept as written.
+	c, _ := NewConstraint("^1.0.0 || ~3.1.0")
+	s := c.Simplify()
+	if s.constraints[0][0] != c.constraints[0][0] || s.constraints[1][0] != c.constraints[1][0] {
+		t.Error("Expected groups that were not merged to be unchanged")
+	}
+
+	// Channel constraints are kept.
+	if s := MatchChannel("beta").Simplify(); len(s.constraints) != 1 || !s.Check(MustParse("1.0.0-beta.1")) {
+		t.Error("Expected channel constraint to be unchanged")
+	}
+}
+
+func TestConstraintsCanonicalString(t *testing.T) {
+	tests := []struct {
+		constraints []string
+		expected    string
+	}{
+		{[]string{">=1.2.0, <2.0.0", "<2.0.0, >=1.2.0", "  <  2.0.0 ,>= 1.2.0 ", "=> v1.2.0, <v2.0.0+build"}, "<2.0.0, >=1.2.0"},
+		{[]string{"1.2.3", "=1.2.3", "= v1.2.3+build.1"}, "=1.2.3"},
+		{[]string{"1.2.x", "1.2.X", "=1.2.*"}, "=1.2.x"},
+		{[]string{"1", "1.x", "1.X.x"}, "=1.x"},
+		{[]string{"*", "x", "X.x.*"}, "=*"},
+		{[]string{"~1.2.0", "~>1.2.0"}, "~1.2.0"},
+		{[]string{"!=1.5.0, ^1.0.0, !=1.3.0", "^1.0.0, !=1.3.0, != 1.5.0"}, "!=1.3.0, !=1.5.0, ^1.0.0"},
+		{[]string{"1.0.0 - 2.0.0", "<= 2.0.0, >= 1.0.0", "1.0.0..=2.0.0", "=<2.0.0, =>1.0.0"}, "<=2.0.0, >=1.0.0"},
+		{[]string{"<2.0.0, >1.0.0 || ~3.1.0", "> 1.0.0,< 2.0.0 ||~>3.1.0"}, "<2.0.0, >1.0.0 || ~3.1.0"},
+		{[]string{"^1.2.0+pre"}, "^1.2.0-0+pre"},
+		{[]string{">=1.0.0-beta, >=1.0.0"}, ">=1.0.0-beta, >=1.0.0"},
+	}
+
+	for _, tc := range tests {
+		for _, s := range tc.constraints {
+			c, err := NewConstraint(s)
+			if err != nil {
+				t.Errorf("err: %s", err)
+				continue
+			}
+
+			if a := c.CanonicalString(); a != tc.expected {
+				t.Errorf("Expected %q to canonicalize to %q but got %q", s, tc.expected, a)
+			}
+		}
+	}
+
+	a, _ := NewConstraint(">=1.0.0")
+	b, _ := NewConstraint(">1.0.0")
+	if a.CanonicalString() == b.CanonicalString() {
+		t.Error("Expected different operators to canonicalize differently")
+	}
+	a, _ = NewConstraintWithOptions(">=1.0.0", ConstraintOptions{IncludePrerelease: true})
+	if a.CanonicalString() != ">=1.0.0+pre" {
+		t.Errorf("Unexpected canonical form %q", a.CanonicalString())
+	}
+	if a := MatchChannel("beta").CanonicalString(); a != "channel:beta" {
+		t.Errorf("Unexpected canonical form %q", a)
+	}
+}
+
+func TestConstraintsCouldMatchMajor(t *testing.T) {
+	tests := []struct {
+		constraint string
+		major      uint64
+		could      bool
+	}{
+		{"^1.0.0", 0, false},
+		{"^1.0.0", 1, true},
+		{"^1.0.0", 2, false},
+		{"^1.0.0", 3, false},
+		{"~1.2.3", 1, true},
+		{"~1.2.3", 2, false},
+		{">=1.5.0, <3.0.0", 0, false},
+		{">=1.5.0, <3.0.0", 2, true},
+		{">=1.5.0, <3.0.0", 3, false},
+		{"<=3.0.0", 3, true},
+		{"<3.0.0-beta", 3, true},
+		{"<3.0.0", 3, false},
+		{"<3.0.0+pre", 3, true},
+		{">=2.0.0-alpha, <3.0.0", 3, false},
+		{">=1.0.0-alpha, !=2.0.0, <1.0.0-z", 1, true},
+		{">=1.0.0-alpha, !=2.x, <1.0.0-z", 1, false},
+		{">2.9.9", 3, true},
+		{"^1.0.0 || ^4.0.0", 2, false},
+		{"^1.0.0 || ^4.0.0", 4, true},
+		{"!=2.x", 2, false},
+		{"!=2.x", 5, true},
+		{">=1.0.0", math.MaxInt64, true},
+		{">=1.0.0", math.MaxInt64 + 1, false},
+		{"*", 0, true},
+		{">3.0.0, <3.0.0", 3, false},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := c.CouldMatchMajor(tc.major); a != tc.could {
+			t.Errorf("Constraint %q with major %d: expected %t but got %t", tc.constraint, tc.major, tc.could, a)
+		}
+	}
+
+	if !MatchChannel("beta").CouldMatchMajor(2) {
+		t.Error("Expected a channel constraint to possibly match any major version")
+	}
+}
+
+func TestConstraintsWiden(t *testing.T) {
+	tests := []struct {
+		constraint string
+		level      string
+		version    string
+		check      bool
+	}{
+		{"=1.2.3", "caret", "1.2.3", true},
+		{"=1.2.3", "caret", "1.9.0", true},
+		{"=1.2.3", "caret", "2.0.0", false},
+		{"=1.2.3", "caret", "1.2.2", false},
+		{"=1.2.3", "tilde", "1.2.9", true},
+		{"=1.2.3", "tilde", "1.3.0", false},
+		{"=1.2.3", "tilde", "1.2.2", false},
+		{"1.2.3", "minor", "1.2.0", true},
+		{"1.2.3", "minor", "1.2.9", true},
+		{"1.2.3", "minor", "1.3.0", false},
+		{"=1.2.3, !=1.5.0", "caret", "1.5.0", false},
+		{"=1.2.3, !=1.5.0", "caret", "1.6.0", true},
+		{"=1.2.3 || =3.0.0", "tilde", "3.0.4", true},
+		{"=1.2.3 || =3.0.0", "tilde", "2.0.0", false},
+		{">=1.0.0, <1.5.0", "caret", "1.6.0", false},
+		{"=1.2.x", "caret", "1.3.0", false},
+		{"=1.2.3-beta.1", "caret", "1.2.3-beta.2", true},
+		{"=1.2.3+pre", "caret", "1.4.0-rc.1", true},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		w, err := c.Widen(tc.level)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := w.Check(MustParse(tc.version)); a != tc.check {
+			t.Errorf("Constraint %q widened to %s failing with %q", tc.constraint, tc.level, tc.version)
+		}
+	}
+
+	c, _ := NewConstraint("=1.2.3")
+	if _, err := c.Widen("major"); err == nil {
+		t.Error("expected but did not get error for unknown level")
+	}
+
+	// The constraints being widened are not changed.
+	if _, err := c.Widen("caret"); err != nil {
+		t.Errorf("err: %s", err)
+	}
+	if c.Check(MustParse("1.9.0")) {
+		t.Error("Widen changed the original constraints")
+	}
+}
+
+func TestConstraintsCountVersions(t *testing.T) {
+	tests := []struct {
+		constraint  string
+		granularity string
+		count       uint64
+		ok          bool
+	}{
+		{">=1.2.3, <=1.2.9", "patch", 7, true},
+		{">1.2.3, <1.2.9", "patch", 5, true},
+		{">=1.2.3-0, <1.2.9-0", "patch", 6, true},
+		{"1.2.3 - 1.2.9, !=1.2.5", "patch", 6, true},
+		{"=1.2.3 || =1.2.5", "patch", 2, true},
+		{"=1.2.3-beta", "patch", 0, true},
+		{"~1.2.0", "patch", math.MaxInt64 + 1, true},
+		{"~1.2.3", "patch", math.MaxInt64 - 2, true},
+		{"~1.2.0", "minor", 1, true},
+		{">=1.2.0, <1.5.0", "minor", 3, true},
+		{">=1.2.0, <=1.5.0", "minor", 4, true},
+		{">=1.2.0, <1.5.0, !=1.3.2", "minor", 3, true},
+		{"<0.3.0", "minor", 3, true},
+		{"<1.5.0", "minor", math.MaxInt64 + 6, true},
+		{"^1.0.0", "minor", math.MaxInt64 + 1, true},
+		{">=1.2.0, <1.5.0", "patch", math.MaxUint64, true},
+		{"^1.0.0", "patch", math.MaxUint64, true},
+		{">=1.0.0", "minor", 0, false},
+		{">=1.0.0", "patch", 0, false},
+		{"~1.2.0 || >=3.0.0", "minor", 0, false},
+		{"~1.2.0", "major", 0, false},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		n, ok := c.CountVersions(tc.granularity)
+		if ok != tc.ok || n != tc.count {
+			t.Errorf("Expected %q to count %d (%t) %s versions but got %d (%t)",
+				tc.constraint, tc.count, tc.ok, tc.granularity, n, ok)
+		}
+	}
+}
+
+func TestConstraintsHeadroom(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		headroom   uint64
+		ok         bool
+	}{
+		{"~1.2.0", "1.2.3", math.MaxUint64, true},
+		{"~1.2.0", "1.2.0", math.MaxUint64, true},
+		{">=1.2.0, <1.2.7", "1.2.3", 3, true},
+		{">=1.2.0, <=1.2.7", "1.2.3", 4, true},
+		{">=1.2.0, <=1.2.7", "1.2.7", 0, true},
+		{">=1.2.0, <1.2.7-beta", "1.2.3", 3, true},
+		{">=1.2.0-alpha, <=1.2.7-rc", "1.2.3-beta", 4, true},
+		{"1.2.3", "1.2.3", 0, true},
+		{"~1.0.0 || >=1.2.0, <1.2.5", "1.2.3", 1, true},
+		{"!=1.2.5, >=1.2.0, <1.3.0", "1.2.3", 1, true},
+		{"~1.2.0", "1.3.0", 0, false},
+		{">=1.2.0", "1.2.3", 0, false},
+		{"^1.0.0", "1.2.3", math.MaxUint64, true},
+		{">=1.2.0-alpha, <1.2.0-beta", "1.2.0-alpha.1", 0, true},
+		{">=1.2.0, <1.2.4 || ~1.3.0", "1.3.5", math.MaxUint64, true},
+		{"^1.0.0 || >=3.0.0", "3.1.0", 0, false},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		h, ok := c.Headroom(MustParse(tc.version))
+		if ok != tc.ok {
+			t.Errorf("Constraint %q with %q: expected ok %t but got %t", tc.constraint, tc.version, tc.ok, ok)
+			continue
+		}
+		if h != tc.headroom {
+			t.Errorf("Constraint %q with %q: expected headroom %d but got %d", tc.constraint, tc.version, tc.headroom, h)
+		}
+	}
+}
+
+func TestConstraintsMaxSatisfying(t *testing.T) {
+	tests := []struct {
+		constraint string
+		expected   string
+	}{
+		{"~1.2.0", "1.2.9223372036854775807"},
+		{"^1.0.0", "1.9223372036854775807.9223372036854775807"},
+		{"<=1.5.3", "1.5.3"},
+		{"<1.5.3", "1.5.2"},
+		{"<1.5.3-rc.1", "1.5.2"},
+		{"<=1.5.3-rc.1", "1.5.2"},
+		{">=1.0.0, <2.0.0, !=1.9223372036854775807.9223372036854775807", "1.9223372036854775807.9223372036854775806"},
+		{"=1.2.3 || =1.0.0", "1.2.3"},
+		{"1.2.3 || =2.0.0-beta", "1.2.3"},
+		{"<0.0.1", "0.0.0"},
+		{"<0.0.0", ""},
+		{">=1.0.0", ""},
+		{"^1.0.0 || >=3.0.0", ""},
+	}
+
+	for _, tc := range tests {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		v, ok := c.MaxSatisfying()
+		if tc.expected == "" {
+			if ok || v != nil {
+				t.Errorf("Expected no maximum for %q but got %s", tc.constraint, v)
+			}
+			continue
+		}
+
+		if !ok || v.String() != tc.expected {
+			t.Errorf("Expected %s as the maximum for %q but got %s", tc.expected, tc.constraint, v)
+			continue
+		}
+		if !c.Check(v) {
+			t.Errorf("Maximum %s does not satisfy %q", v, tc.constraint)
+		}
+	}
+}
+
+func TestRewriteRange(t *testing.T) {
+	tests := []struct {
+		c  string
+		nc string
+	}{
+		{"2 - 3", ">= 2.0.0, < 4.0.0"},
+		{"2 - 3, 2 - 3", ">= 2.0.0, < 4.0.0,>= 2.0.0, < 4.0.0"},
+		{"2 - 3, 4.0.0 - 5.1", ">= 2.0.0, < 4.0.0,>= 4.0.0, <= 5.1.0"},
+		{"1.2.3 - 2.0.0", ">= 1.2.3, <= 2.0.0"},
+		{"1 - 2,1 - 2 || 1 - 2", ">= 1.0.0, < 3.0.0,>= 1.0.0, < 3.0.0||>= 1.0.0, < 3.0.0"},
+		{"13 - 4,3 - 4", ">= 13.0.0, < 5.0.0,>= 3.0.0, < 5.0.0"},
+		{"3 - 4,13 - 4", ">= 3.0.0, < 5.0.0,>= 13.0.0, < 5.0.0"},
+		{"1.0 - 2.0, 1.0 - 2.0-beta", ">= 1.0.0, <= 2.0.0,>= 1.0.0, <= 2.0.0-beta"},
+		{"^1.0.0, 1 - 2", "^1.0.0,>= 1.0.0, < 3.0.0"},
+		{"2 - 3.1", ">= 2.0.0, <= 3.1.0"},
+		{"1.x - 2.1.x", ">= 1.0.0, < 2.2.0"},
+		{"v1.2 - v2", ">= 1.2.0, < 3.0.0"},
+		{"1.0.0-beta - 2-rc", ">= 1.0.0-beta, < 3.0.0-0"},
+	}
+
+	for _, tc := range tests {
+		o := rewriteRange(tc.c)
+
+		if o != tc.nc {
+			t.Errorf("Range %s rewritten incorrectly as '%s'", tc.c, o)
+		}
+	}
+
+	// Carets and tildes are checked as they are written rather than being
+	// rewritten, so only the ranges alongside them change.
+	rewrites := []struct {
+		c  string
+		nc string
+	}{
+		{"^1.2", "^1.2"},
+		{"~1", "~1"},
+		{"~1.2.x, 1 - 2", "~1.2.x,>= 1.0.0, < 3.0.0"},
+		{"^1, 1.2..2", "^1,>= 1.2.0, < 2.0.0"},
+		{"~>2.1 || 2.1..=3", "~>2.1 ||>= 2.1.0, < 4.0.0"},
+	}
+
+	for _, tc := range rewrites {
+		o := tc.c
+		for _, f := range rewriteFuncs {
+			o = f(o)
+		}
+
+		if o != tc.nc {
+			t.Errorf("Constraint %s rewritten incorrectly as '%s'", tc.c, o)
+		}
+	}
+
+	// The filled in bounds match the same versions as the partial versions
+	// they replace. A bare major upper bound covers the whole of its major
+	// version, so 2 - 3 includes 3.5.0 while 2 - 3.0.0 does not. A partial
+	// minor upper bound does not cover its minor version, so 2 - 3.1 does
+	// not include 3.1.5.
+	checks := []struct {
+		c     string
+		v     string
+		check bool
+	}{
+		{"2 - 3", "3.5.0", true},
+		{"2 - 3.0.0", "3.5.0", false},
+		{"2 - 3.1", "3.1.5", false},
+		{"2 - 3.1", "3.1.0", true},
+		{"2 - 3", "4.0.0", false},
+		{"1.x - 2.1.x", "2.1.9", true},
+		{"1.x - 2.1.x", "2.2.0", false},
+		{"^1.0.0 || ^1.0.0", "1.5.0", true},
+		{"~1.2 || ~1.2, 1.2.0 - 1.2.5", "1.2.7", true},
+		{"~1.2, 1.2.0 - 1.2.5 || ~1.2, 1.2.0 - 1.2.5", "1.2.7", false},
+		{"1 - 2, 1 - 2 || 1 - 2", "2.5.0", true},
+	}
+
+	for _, tc := range checks {
+		c, err := NewConstraint(tc.c)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := c.Check(MustParse(tc.v)); a != tc.check {
+			t.Errorf("Range '%s' failing with '%s'", tc.c, tc.v)
+		}
+	}
+}
+
+func TestRewriteRustRange(t *testing.T) {
+	tests := []struct {
+		c  string
+		nc string
+	}{
+		{"1.2.0..2.0.0", ">= 1.2.0, < 2.0.0"},
+		{"1.2.0..=2.0.0", ">= 1.2.0, <= 2.0.0"},
+		{" 1.2.0-rc.1..=2.0.0-beta ", ">= 1.2.0-rc.1, <= 2.0.0-beta"},
+		{"1..2", ">= 1.0.0, < 2.0.0"},
+		{"1..=2", ">= 1.0.0, < 3.0.0"},
+		{"1.2..2.1", ">= 1.2.0, < 2.1.0"},
+		{"1.2..=2.1", ">= 1.2.0, <= 2.1.0"},
+		{"1.x..=2.1.x", ">= 1.0.0, < 2.2.0"},
+		{"1.2.0 - 2.0.0", "1.2.0 - 2.0.0"},
+		{"^1.2.0", "^1.2.0"},
+	}
+
+	for _, tc := range tests {
+		o := rewriteRustRange(tc.c)
+
+		if o != tc.nc {
+			t.Errorf("Range %s rewritten incorrectly as '%s'", tc.c, o)
+		}
+	}
+
+	checks := []struct {
+		c     string
+		v     string
+		check bool
+	}{
+		{"1.2.0..2.0.0", "1.2.0", true},
+		{"1.2.0..2.0.0", "1.9.9", true},
+		{"1.2.0..2.0.0", "2.0.0", false},
+		{"1.2.0..2.0.0", "1.1.9", false},
+		{"1.2.0..=2.0.0", "2.0.0", true},
+		{"1.2.0..=2.0.0", "2.0.1", false},
+		{"1.2.0..2.0.0, !=1.5.0 || 3.0.0..=3.1.0", "3.1.0", true},
+		{"1.2.0..2.0.0, !=1.5.0 || 3.0.0..=3.1.0", "1.5.0", false},
+		{"1..2", "1.9.9", true},
+		{"1..2", "2.0.0", false},
+		{"1..2", "2.5.0", false},
+		{"1..=2", "2.5.0", true},
+		{"1..=2", "3.0.0", false},
+	}
+
+	for _, tc := range checks {
+		c, err := NewConstraint(tc.c)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := c.Check(MustParse(tc.v)); a != tc.check {
+			t.Errorf("Range '%s' failing with '%s'", tc.c, tc.v)
+		}
+	}
+
+	if _, err := NewStrictConstraint("1.2.0..2.0.0"); err == nil {
+		t.Error("expected but did not get error for strict Rust range")
+	}
+}
+
+func TestRewriteAdjacentTerms(t *testing.T) {
+	tests := []struct {
+		c  string
+		nc string
+	}{
+		{">=1.2.0<2.0.0", ">=1.2.0, <2.0.0"},
+		{">1.0.0<=2.0.0", ">1.0.0, <=2.0.0"},
+		{"=>1.0.0=<2.0.0", "=>1.0.0, =<2.0.0"},
+		{" >= 1.2.0< 2.0.0 ", ">= 1.2.0, < 2.0.0"},
+		{">=1.2.0-beta.1<2.0.0-0!=1.5.0", ">=1.2.0-beta.1, <2.0.0-0, !=1.5.0"},
+		{">=1.x<2", ">=1.x, <2"},
+		{"^1.2.0!=1.3.0", "^1.2.0, !=1.3.0"},
+		{">=1.0.0<2.0.0, !=1.5.0", ">=1.0.0, <2.0.0, !=1.5.0"},
+		{"<=1.0.0", "<=1.0.0"},
+		{">=1.0.0", ">=1.0.0"},
+		{">=1.0.0 <2.0.0", ">=1.0.0 <2.0.0"},
+		{"1.0.0<2.0.0", "1.0.0<2.0.0"},
+		{">= 1.2.0, < 2.0.0", ">= 1.2.0, < 2.0.0"},
+	}
+
+	for _, tc := range tests {
+		o := rewriteAdjacentTerms(tc.c)
+
+		if o != tc.nc {
+			t.Errorf("Constraint %s rewritten incorrectly as '%s'", tc.c, o)
+		}
+	}
+
+	checks := []struct {
+		c     string
+		v     string
+		check bool
+	}{
+		{">=1.2.0<2.0.0", "1.2.0", true},
+		{">=1.2.0<2.0.0", "1.9.9", true},
+		{">=1.2.0<2.0.0", "2.0.0", false},
+		{">=1.2.0<2.0.0", "1.1.9", false},
+		{">1.0.0<=2.0.0", "1.0.0", false},
+		{">1.0.0<=2.0.0", "2.0.0", true},
+		{">1.0.0<=2.0.0", "2.0.1", false},
+		{"<=1.0.0", "1.0.0", true},
+		{"<=1.0.0", "1.0.1", false},
+		{">=1.2.0<2.0.0 || >=3.0.0<3.1.0", "3.0.5", true},
+		{">=1.2.0<2.0.0 || >=3.0.0<3.1.0", "2.5.0", false},
+	}
+
+	for _, tc := range checks {
+		c, err := NewConstraint(tc.c)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := c.Check(MustParse(tc.v)); a != tc.check {
+			t.Errorf("Constraint '%s' failing with '%s'", tc.c, tc.v)
+		}
+	}
+
+	if _, err := NewConstraint(">=1.0.0 <2.0.0"); err == nil {
+		t.Error("expected but did not get error for space separated terms")
+	}
+	if _, err := NewStrictConstraint(">=1.0.0<2.0.0"); err == nil {
+		t.Error("expected but did not get error for strict adjacent terms")
+	}
 }
 
 func TestIsX(t *testing.T) {
@@ -443,7 +2308,7 @@ func TestConstraintsValidate(t *testing.T) {
 		{">=1.1, <2, !=1.2.3", "1.2.3", "1.2.3 is equal to 1.2.3"},
 		{">=1.1, <2, !=1.2.3 || > 3", "3.0.0", "3.0.0 is greater than or equal to 2"},
 		{">=1.1, <2, !=1.2.3 || > 3", "1.2.3", "1.2.3 is equal to 1.2.3"},
-		{"1.1 - 3", "4.3.2", "4.3.2 is greater than 3"},
+		{"1.1 - 3", "4.3.2", "4.3.2 is greater than or equal to 4.0.0"},
 		{"^1.1", "4.3.2", "4.3.2 does not have same major version as 1.1"},
 		{"^2.x", "1.1.1", "1.1.1 does not have same major version as 2.x"},
 		{"^1.x", "2.1.1", "2.1.1 does not have same major version as 1.x"},
@@ -71,13 +71,36 @@ The basic comparisons are:
     * `>=`: greater than or equal to
     * `<=`: less than or equal to
 
+Terms written one after the other without a comma, such as `>=1.2.0<2.0.0`,
+are also and comparisons. A version can not contain an operator character so
+each term ends where the next operator begins, and `<=1.0.0` remains a single
+term. Every term needs an operator and no space can come between a version
+and the next operator.
+
+Versions with a pre-release are skipped by terms without a pre-release of
+their own. For example, `>= 1.2.3` skips `1.3.0-beta` while `>= 1.2.3-0`
+matches it. To include pre-releases for a single term add `+pre` to its
+version. For example, `1.4.x+pre` matches `1.4.2` and `1.4.0-rc1` but not
+`1.5.0`. Other terms in the same constraint keep skipping pre-releases.
+
 Hyphen Range Comparisons
 
 There are multiple methods to handle ranges and the first is hyphens ranges.
 These look like:
 
-    * `1.2 - 1.4.5` which is equivalent to `>= 1.2, <= 1.4.5`
-    * `2.3.4 - 4.5` which is equivalent to `>= 2.3.4, <= 4.5`
+    * `1.2 - 1.4.5` which is equivalent to `>= 1.2.0, <= 1.4.5`
+    * `2.3.4 - 4.5` which is equivalent to `>= 2.3.4, <= 4.5.0`
+    * `2 - 3` which is equivalent to `>= 2.0.0, < 4.0.0`
+
+Rust style ranges are also supported. The `..` operator excludes the upper
+bound while `..=` includes it:
+
+    * `1.2.0..2.0.0` which is equivalent to `>= 1.2.0, < 2.0.0`
+    * `1.2.0..=2.0.0` which is equivalent to `>= 1.2.0, <= 2.0.0`
+
+A partial upper bound is filled in with zeros, so `1..2` excludes all of the
+2.x releases, while with `..=` it includes every release it covers, so
+`1..=2` is equivalent to `>= 1.0.0, < 3.0.0`.
 
 Wildcards In Comparisons
 
@@ -89,6 +112,7 @@ back to the pack level comparison (see tilde below). For example,
     * `>= 1.2.x` is equivalent to `>= 1.2.0`
     * `<= 2.x` is equivalent to `<= 3`
     * `*` is equivalent to `>= 0.0.0`
+    * `1` is equivalent to `1.x`, `>= 1.0.0, < 2.0.0`
 
 Tilde Range Comparisons (Patch)
 
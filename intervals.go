@@ -0,0 +1,399 @@
+package semver
+
+import (
+	"math"
+	"sort"
+)
+
+// A bound is one end of an interval of versions. A nil version means the
+// interval is unbounded on that side.
+type bound struct {
+	v         *Version
+	inclusive bool
+}
+
+// An interval is a contiguous range of versions between a lower and an upper
+// bound. Versions are ordered by Compare, so pre-releases fall between the
+// releases around them.
+type interval struct {
+	lower, upper bound
+}
+
+// intervals is a union of intervals. Once normalized the intervals are sorted,
+// non-empty, and neither overlap nor touch each other. This gives every set
+// of versions a single representation so sets can be compared.
+type intervals []interval
+
+// allVersions is the interval containing every version.
+var allVersions = intervals{{}}
+
+func (i interval) empty() bool {
+	if i.lower.v == nil || i.upper.v == nil {
+		return false
+	}
+
+	d := i.lower.v.Compare(i.upper.v)
+	return d > 0 || (d == 0 && !(i.lower.inclusive && i.upper.inclusive))
+}
+
+// compareLower compares two lower bounds. An unbounded lower bound is the
+// smallest and an inclusive bound is smaller than an exclusive one on the
+// same version.
+func compareLower(a, b bound) int {
+	if a.v == nil || b.v == nil {
+		return compareUnbounded(a, b)
+	}
+	if d := a.v.Compare(b.v); d != 0 {
+		return d
+	}
+	if a.inclusive == b.inclusive {
+		return 0
+	}
+	if a.inclusive {
+		return -1
+	}
+	return 1
+}
+
+// compareUpper compares two upper bounds. An unbounded upper bound is the
+// largest and an inclusive bound is larger than an exclusive one on the
+// same version.
+func compareUpper(a, b bound) int {
+	if a.v == nil || b.v == nil {
+		return -compareUnbounded(a, b)
+	}
+	if d := a.v.Compare(b.v); d != 0 {
+		return d
+	}
+	if a.inclusive == b.inclusive {
+		return 0
+	}
+	if a.inclusive {
+		return 1
+	}
+	return -1
+}
+
+// compareUnbounded orders bounds when at least one of them is unbounded,
+// treating the unbounded one as the smaller.
+func compareUnbounded(a, b bound) int {
+	switch {
+	case a.v == nil && b.v == nil:
+		return 0
+	case a.v == nil:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (is intervals) normalize() intervals {
+	var o intervals
+	for _, i := range is {
+		if !i.empty() {
+			o = append(o, i)
+		}
+	}
+
+	sort.Slice(o, func(x, y int) bool {
+		return compareLower(o[x].lower, o[y].lower) < 0
+	})
+
+	var r intervals
+	for _, i := range o {
+		if len(r) == 0 {
+			r = append(r, i)
+			continue
+		}
+
+		// Merge the interval into the previous one when they overlap or
+		// touch (e.g., [1.0.0, 2.0.0) and [2.0.0, 3.0.0)).
+		last := &r[len(r)-1]
+		if last.upper.v != nil && i.lower.v != nil {
+			d := i.lower.v.Compare(last.upper.v)
+			if d > 0 || (d == 0 && !last.upper.inclusive && !i.lower.inclusive) {
+				r = append(r, i)
+				continue
+			}
+		}
+		if compareUpper(i.upper, last.upper) > 0 {
+			last.upper = i.upper
+		}
+	}
+
+	return r
+}
+
+func (is intervals) union(o intervals) intervals {
+	u := make(intervals, 0, len(is)+len(o))
+	u = append(u, is...)
+	u = append(u, o...)
+	return u.normalize()
+}
+
+func (is intervals) intersect(o intervals) intervals {
+	var r intervals
+	for _, a := range is {
+		for _, b := range o {
+			i := interval{lower: a.lower, upper: a.upper}
+			if compareLower(b.lower, i.lower) > 0 {
+				i.lower = b.lower
+			}
+			if compareUpper(b.upper, i.upper) < 0 {
+				i.upper = b.upper
+			}
+			r = append(r, i)
+		}
+	}
+	return r.normalize()
+}
+
+// complement returns the versions not in a normalized set of intervals.
+func (is intervals) complement() intervals {
+	var r intervals
+	lower := bound{}
+	for _, i := range is {
+		if i.lower.v != nil {
+			r = append(r, interval{
+				lower: lower,
+				upper: bound{v: i.lower.v, inclusive: !i.lower.inclusive},
+			})
+		}
+		if i.upper.v == nil {
+			return r.normalize()
+		}
+		lower = bound{v: i.upper.v, inclusive: !i.upper.inclusive}
+	}
+	r = append(r, interval{lower: lower})
+	return r.normalize()
+}
+
+func (is intervals) equal(o intervals) bool {
+	if len(is) != len(o) {
+		return false
+	}
+	for k := range is {
+		if compareLower(is[k].lower, o[k].lower) != 0 ||
+			compareUpper(is[k].upper, o[k].upper) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// subset reports if every version in the normalized intervals is also in o.
+func (is intervals) subset(o intervals) bool {
+	return is.intersect(o).equal(is)
+}
+
+// contains reports if a version is in the normalized intervals. As the
+// intervals are sorted a binary search is used.
+func (is intervals) contains(v *Version) bool {
+	k := sort.Search(len(is), func(k int) bool {
+		u := is[k].upper
+		if u.v == nil {
+			return true
+		}
+		d := v.Compare(u.v)
+		return d < 0 || (d == 0 && u.inclusive)
+	})
+	if k == len(is) {
+		return false
+	}
+
+	l := is[k].lower
+	if l.v == nil {
+		return true
+	}
+	d := v.Compare(l.v)
+	return d > 0 || (d == 0 && l.inclusive)
+}
+
+// releases returns the lowest and highest versions without a pre-release in
+// the interval. False is returned if the interval has no upper bound or has
+// no such versions. Version segments can not be larger than math.MaxInt64 so
+// the versions just below 1.3.0 are taken to be 1.2.9223372036854775807.
+func (i interval) releases() (*Version, *Version, bool) {
+	if i.upper.v == nil {
+		return nil, nil, false
+	}
+
+	lo := &Version{}
+	if l := i.lower.v; l != nil {
+		lo = &Version{major: l.major, minor: l.minor, patch: l.patch}
+		if l.pre == "" && !i.lower.inclusive {
+			var ok bool
+			if lo, ok = nextRelease(lo); !ok {
+				return nil, nil, false
+			}
+		}
+	}
+
+	u := i.upper.v
+	hi := &Version{major: u.major, minor: u.minor, patch: u.patch}
+	if u.pre != "" || !i.upper.inclusive {
+		var ok bool
+		if hi, ok = prevRelease(hi); !ok {
+			return nil, nil, false
+		}
+	}
+
+	if lo.Compare(hi) > 0 {
+		return nil, nil, false
+	}
+	return lo, hi, true
+}
+
+// nextRelease returns the release immediately after v, ignoring any
+// pre-release or metadata on v.
+func nextRelease(v *Version) (*Version, bool) {
+	switch {
+	case v.patch < math.MaxInt64:
+		return &Version{major: v.major, minor: v.minor, patch: v.patch + 1}, true
+	case v.minor < math.MaxInt64:
+		return &Version{major: v.major, minor: v.minor + 1}, true
+	case v.major < math.MaxInt64:
+		return &Version{major: v.major + 1}, true
+	}
+	return nil, false
+}
+
+// prevRelease returns the release immediately before v, ignoring any
+// pre-release or metadata on v.
+func prevRelease(v *Version) (*Version, bool) {
+	switch {
+	case v.patch > 0:
+		return &Version{major: v.major, minor: v.minor, patch: v.patch - 1}, true
+	case v.minor > 0:
+		return &Version{major: v.major, minor: v.minor - 1, patch: math.MaxInt64}, true
+	case v.major > 0:
+		return &Version{major: v.major - 1, minor: math.MaxInt64, patch: math.MaxInt64}, true
+	}
+	return nil, false
+}
+
+// constraints converts normalized intervals into Constraints with an or
+// group for each interval.
+func (is intervals) constraints() *Constraints {
+	or := make([][]*constraint, 0, len(is))
+	for _, i := range is {
+		var and []*constraint
+		switch {
+		case i.lower.v == nil && i.upper.v == nil:
+			c, _ := parseConstraint("*", ConstraintOptions{})
+			and = append(and, c)
+		case i.lower.v != nil && i.upper.v != nil &&
+			i.lower.v.Equal(i.upper.v):
+			and = append(and, newConstraint("=", i.lower.v))
+		default:
+			if i.lower.v != nil {
+				op := ">"
+				if i.lower.inclusive {
+					op = ">="
+				}
+				and = append(and, newConstraint(op, i.lower.v))
+			}
+			if i.upper.v != nil {
+				op := "<"
+				if i.upper.inclusive {
+					op = "<="
+				}
+				and = append(and, newConstraint(op, i.upper.v))
+			}
+		}
+		or = append(or, and)
+	}
+
+	return &Constraints{constraints: or}
+}
+
+// intervals returns the set of versions allowed by the constraints. The
+// second return value is false when a constraint can not be represented as
+// intervals (e.g., a channel constraint).
+//
+// Intervals do not model the rule that a version with a pre-release only
+// satisfies a constraint that has a pre-release itself. Pre-releases are
+// ordered the same way as Compare orders them.
+func (cs Constraints) intervals() (intervals, bool) {
+	var r intervals
+	for _, o := range cs.constraints {
+		and := allVersions
+		for _, c := range o {
+			i, ok := c.intervals()
+			if !ok {
+				return nil, false
+			}
+			and = and.intersect(i)
+		}
+		r = r.union(and)
+	}
+	return r, true
+}
+
+// intervals returns the set of versions allowed by a single constraint.
+func (c *constraint) intervals() (intervals, bool) {
+	if c.con == nil {
+		return nil, false
+	}
+
+	con := bound{v: c.con, inclusive: true}
+	switch c.op {
+	case "", "=":
+		if c.dirty {
+			return c.tildeIntervals(), true
+		}
+		return intervals{{lower: con, upper: con}}, true
+	case "!=":
+		if !c.dirty {
+			return intervals{{lower: con, upper: con}}.complement(), true
+		}
+		if !c.minorDirty && !c.patchDirty {
+			return nil, true
+		}
+		return intervals{{lower: con, upper: c.wildcardUpper()}}.complement(), true
+	case ">":
+		return intervals{{lower: bound{v: c.con}}}, true
+	case ">=", "=>":
+		return intervals{{lower: con}}, true
+	case "<":
+		if c.dirty {
+			return intervals{{upper: c.wildcardUpper()}}, true
+		}
+		return intervals{{upper: bound{v: c.con}}}, true
+	case "<=", "=<":
+		if c.dirty {
+			return intervals{{upper: c.wildcardUpper()}}, true
+		}
+		return intervals{{upper: con}}, true
+	case "~", "~>":
+		return c.tildeIntervals(), true
+	case "^":
+		return intervals{{lower: con, upper: bound{v: &Version{major: c.con.major + 1}}}}, true
+	}
+
+	return nil, false
+}
+
+func (c *constraint) tildeIntervals() intervals {
+	con := bound{v: c.con, inclusive: true}
+
+	// ~0.0.0 and ~* accept every version.
+	if c.con.major == 0 && c.con.minor == 0 && c.con.patch == 0 &&
+		!c.minorDirty && !c.patchDirty {
+		return intervals{{lower: con}}
+	}
+
+	if c.minorDirty {
+		return intervals{{lower: con, upper: bound{v: &Version{major: c.con.major + 1}}}}
+	}
+	return intervals{{lower: con, upper: bound{v: &Version{major: c.con.major, minor: c.con.minor + 1}}}}
+}
+
+// wildcardUpper returns the exclusive upper bound of the versions matched by
+// the wildcard in a constraint. For example, 1.3.0 for 1.2.x.
+func (c *constraint) wildcardUpper() bound {
+	if c.minorDirty {
+		return bound{v: &Version{major: c.con.major + 1}}
+	}
+	return bound{v: &Version{major: c.con.major, minor: c.con.minor + 1}}
+}
@@ -0,0 +1,85 @@
+package semver
+
+import "testing"
+
+func TestIntervalsNormalize(t *testing.T) {
+	v := MustParse
+	tests := []struct {
+		in, out intervals
+	}{
+		// Overlapping intervals are merged.
+		{
+			intervals{
+				{bound{v("1.0.0"), true}, bound{v("1.5.0"), false}},
+				{bound{v("1.4.0"), true}, bound{v("2.0.0"), false}},
+			},
+			intervals{{bound{v("1.0.0"), true}, bound{v("2.0.0"), false}}},
+		},
+		// Touching intervals are merged.
+		{
+			intervals{
+				{bound{v("1.5.0"), true}, bound{v("2.0.0"), false}},
+				{bound{v("1.0.0"), true}, bound{v("1.5.0"), false}},
+			},
+			intervals{{bound{v("1.0.0"), true}, bound{v("2.0.0"), false}}},
+		},
+		// A gap of a single excluded version is kept.
+		{
+			intervals{
+				{bound{v("1.0.0"), true}, bound{v("1.5.0"), false}},
+				{bound{v("1.5.0"), false}, bound{v("2.0.0"), false}},
+			},
+			intervals{
+				{bound{v("1.0.0"), true}, bound{v("1.5.0"), false}},
+				{bound{v("1.5.0"), false}, bound{v("2.0.0"), false}},
+			},
+		},
+		// Empty intervals are dropped.
+		{
+			intervals{
+				{bound{v("2.0.0"), true}, bound{v("1.0.0"), false}},
+				{bound{v("1.0.0"), true}, bound{v("1.0.0"), false}},
+				{bound{}, bound{v("1.0.0"), true}},
+				{bound{}, bound{v("0.5.0"), true}},
+			},
+			intervals{{bound{}, bound{v("1.0.0"), true}}},
+		},
+	}
+
+	for _, tc := range tests {
+		if a := tc.in.normalize(); !a.equal(tc.out) {
+			t.Errorf("Expected %v to normalize to %v but got %v", tc.in, tc.out, a)
+		}
+	}
+}
+
+func TestIntervalsComplement(t *testing.T) {
+	v := MustParse
+	tests := []struct {
+		in, out intervals
+	}{
+		{nil, allVersions},
+		{allVersions, nil},
+		{
+			intervals{{bound{v("1.0.0"), true}, bound{v("2.0.0"), false}}},
+			intervals{
+				{bound{}, bound{v("1.0.0"), false}},
+				{bound{v("2.0.0"), true}, bound{}},
+			},
+		},
+		{
+			intervals{{bound{}, bound{v("1.0.0"), true}}},
+			intervals{{bound{v("1.0.0"), false}, bound{}}},
+		},
+	}
+
+	for _, tc := range tests {
+		a := tc.in.complement()
+		if !a.equal(tc.out) {
+			t.Errorf("Expected complement of %v to be %v but got %v", tc.in, tc.out, a)
+		}
+		if b := a.complement(); !b.equal(tc.in) {
+			t.Errorf("Expected complement of %v to be %v but got %v", a, tc.in, b)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"regexp"
 	"strconv"
 	"strings"
@@ -37,11 +38,18 @@ const SemVerRegex string = `v?([0-9]+)(\.[0-9]+)?(\.[0-9]+)?` +
 const ValidPrerelease string = `^([0-9A-Za-z\-]+(\.[0-9A-Za-z\-]+)*)`
 
 // Version represents a single semantic version.
+//
+// The zero value is the version 0.0.0 and is ready to use. It compares below
+// every other version except the pre-releases of 0.0.0 and has an empty
+// Original. Use IsZero to detect it, such as for an unset struct field.
 type Version struct {
 	major, minor, patch int64
 	pre                 string
 	metadata            string
 	original            string
+
+	// The prefix removed by NewVersionWithOptions, such as release-.
+	prefix string
 }
 
 func init() {
@@ -93,6 +101,115 @@ func NewVersion(v string) (*Version, error) {
 	return sv, nil
 }
 
+// VersionOptions alters how a version is parsed by NewVersionWithOptions.
+type VersionOptions struct {
+	// Prefixes are removed from the start of a version before it is parsed.
+	// The first prefix that matches is removed, so longer prefixes should be
+	// listed before shorter ones that they start with. When empty, nothing
+	// is removed and the version is parsed exactly as NewVersion parses it,
+	// including its optional leading v.
+	Prefixes []string
+}
+
+// NewVersionWithOptions parses a given version using the passed in options
+// and returns an instance of Version or an error if unable to parse the
+// version. For example, with the prefix release- the version release-1.2.3
+// can be parsed. The prefix is kept in the value returned by Original.
+//
+// A leading v is always accepted after any prefix, as it is by NewVersion.
+func NewVersionWithOptions(v string, opts VersionOptions) (*Version, error) {
+	var prefix string
+	for _, p := range opts.Prefixes {
+		if p != "" && strings.HasPrefix(v, p) {
+			prefix = p
+			break
+		}
+	}
+
+	sv, err := NewVersion(strings.TrimPrefix(v, prefix))
+	if err != nil {
+		return nil, err
+	}
+	sv.original = v
+	sv.prefix = prefix
+
+	return sv, nil
+}
+
+// ParseTag finds and parses the version in a tag, such as a git tag. The
+// version may follow a path (e.g., release/v1.2.3) or a name and a separator
+// (e.g., app-1.2.3 or app_1.2.3), and may have a leading v. The first
+// position after a /, -, _, or @ where the rest of the tag is a version with
+// a major, minor, and patch version is used, so release-2023-1.2.3 is 1.2.3
+// rather than 2023.0.0-1.2.3. Only when there is no such position is a
+// partial version, such as app-1.2, used. The tag is kept in the value
+// returned by Original. An error is returned if the tag does not end in a
+// version.
+func ParseTag(tag string) (*Version, error) {
+	for _, full := range []bool{true, false} {
+		for i := 0; i < len(tag); i++ {
+			if i > 0 && !strings.ContainsRune("/-_@", rune(tag[i-1])) {
+				continue
+			}
+
+			m := versionRegex.FindStringSubmatch(tag[i:])
+			if m == nil || full && (m[2] == "" || m[3] == "") {
+				continue
+			}
+
+			sv, err := NewVersion(tag[i:])
+			if err != nil {
+				continue
+			}
+			sv.original = tag
+			sv.prefix = tag[:i]
+			return sv, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No semantic version found in tag: %s", tag)
+}
+
+// Validate checks that the version, as it was originally written, follows
+// the SemVer 2.0.0 specification strictly. NewVersion is lenient and accepts
+// versions such as 1.2 and 01.2.3 which the specification does not. An error
+// describing the first problem found is returned. The optional leading v is
+// permitted, as is a prefix removed by NewVersionWithOptions.
+func (v *Version) Validate() error {
+	s := strings.TrimPrefix(v.original, v.prefix)
+	if s == "" {
+		s = v.String()
+	}
+
+	m := versionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return ErrInvalidSemVer
+	}
+
+	if m[2] == "" || m[3] == "" {
+		return fmt.Errorf("Version %s is missing a minor or patch version", s)
+	}
+
+	for _, p := range []string{m[1], m[2][1:], m[3][1:]} {
+		if len(p) > 1 && p[0] == '0' {
+			return fmt.Errorf("Version segment %s has a leading zero", p)
+		}
+	}
+
+	if m[4] != "" {
+		for _, p := range strings.Split(m[5], ".") {
+			if p == "" {
+				return fmt.Errorf("Prerelease %s has an empty identifier", m[5])
+			}
+			if len(p) > 1 && p[0] == '0' && isNumeric(p) {
+				return fmt.Errorf("Prerelease identifier %s has a leading zero", p)
+			}
+		}
+	}
+
+	return nil
+}
+
 // MustParse parses a given version and panics on error.
 func MustParse(v string) *Version {
 	sv, err := NewVersion(v)
@@ -121,6 +238,19 @@ func (v *Version) String() string {
 	return buf.String()
 }
 
+// StringWithoutMetadata converts a Version object to a string without any
+// build metadata. The pre-release, if present, is retained.
+func (v *Version) StringWithoutMetadata() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		fmt.Fprintf(&buf, "-%s", v.pre)
+	}
+
+	return buf.String()
+}
+
 // Original returns the original value passed in to be parsed.
 func (v *Version) Original() string {
 	return v.original
@@ -146,19 +276,38 @@ func (v *Version) Prerelease() string {
 	return v.pre
 }
 
+// PrereleaseChannel returns the first dot separated identifier of the
+// pre-release. For example, the channel of 1.2.3-rc.1 is rc. An empty string
+// is returned when there is no pre-release.
+func (v *Version) PrereleaseChannel() string {
+	if i := strings.IndexByte(v.pre, '.'); i >= 0 {
+		return v.pre[:i]
+	}
+	return v.pre
+}
+
 // Metadata returns the metadata on the version.
 func (v *Version) Metadata() string {
 	return v.metadata
 }
 
-// originalVPrefix returns the original 'v' prefix if any.
+// IsZero tests if the version is 0.0.0 without a pre-release or metadata,
+// which is the zero value of a Version.
+func (v *Version) IsZero() bool {
+	return v.major == 0 && v.minor == 0 && v.patch == 0 &&
+		v.pre == "" && v.metadata == ""
+}
+
+// originalVPrefix returns the original 'v' prefix if any. A prefix removed
+// by NewVersionWithOptions or ParseTag is returned ahead of it.
 func (v *Version) originalVPrefix() string {
+	o := strings.TrimPrefix(v.original, v.prefix)
 
 	// Note, only lowercase v is supported as a prefix by the parser.
-	if v.original != "" && v.original[:1] == "v" {
-		return v.original[:1]
+	if o != "" && o[:1] == "v" {
+		return v.prefix + o[:1]
 	}
-	return ""
+	return v.prefix
 }
 
 // IncPatch produces the next patch version.
@@ -240,6 +389,15 @@ func (v Version) SetMetadata(metadata string) (Version, error) {
 	return vNext, nil
 }
 
+// WithBuildCounter sets the metadata to build.<n> for CI systems that number
+// their builds. As with any metadata, the counter does not affect precedence
+// so versions differing only in their counter are Equal.
+func (v Version) WithBuildCounter(n uint64) Version {
+	// build.<n> is always valid metadata so SetMetadata can not fail.
+	vNext, _ := v.SetMetadata("build." + strconv.FormatUint(n, 10))
+	return vNext
+}
+
 // LessThan tests if one version is less than another one.
 func (v *Version) LessThan(o *Version) bool {
 	return v.Compare(o) < 0
@@ -257,6 +415,27 @@ func (v *Version) Equal(o *Version) bool {
 	return v.Compare(o) == 0
 }
 
+// Satisfies tests if the version satisfies a single constraint operator
+// applied to the other version, without parsing a constraint. For example,
+// v.Satisfies(">=", base) is the same as checking v against >= base. The
+// operators are the same as those in a constraint string (e.g., >=, ~, ^). An
+// error is returned for an unknown operator.
+func (v *Version) Satisfies(op string, other *Version) (bool, error) {
+	if _, ok := constraintOps[op]; !ok {
+		return false, fmt.Errorf("unknown operator '%s'", op)
+	}
+
+	return newConstraint(op, other).check(v), nil
+}
+
+// APICompatible tests if the other version is compatible with what this
+// version promises under SemVer. That is, o has the same major version and is
+// not older than v. For example, 1.4.0 is compatible with 1.2.0 while 1.1.0
+// and 2.0.0 are not.
+func (v *Version) APICompatible(o *Version) bool {
+	return o.Major() == v.Major() && o.Compare(v) >= 0
+}
+
 // Compare compares this version to another one. It returns -1, 0, or 1 if
 // the version smaller, equal, or larger than the other version.
 //
@@ -292,6 +471,38 @@ func (v *Version) Compare(o *Version) int {
 	return comparePrerelease(ps, po)
 }
 
+// Hash returns a stable FNV-1a hash of the version for use as a cache key.
+// Metadata is not part of the hash, so versions that are Equal, such as
+// 1.0.0+a and 1.0.0+b, have the same hash.
+func (v *Version) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(v.key()))
+	return h.Sum64()
+}
+
+// key returns a string that is the same for versions that are Equal and
+// different otherwise. Metadata is left out and numeric pre-release
+// identifiers are written as numbers, so those that are equal but written
+// differently (e.g., 01 and 1) have the same key.
+func (v *Version) key() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d.%d.%d", v.major, v.minor, v.patch)
+
+	sep := "-"
+	for pre := v.pre; pre != ""; {
+		var p string
+		p, pre = nextPrePart(pre)
+		if n, ok := prePartNumber(p); ok {
+			fmt.Fprintf(&buf, "%s%d", sep, n)
+		} else {
+			fmt.Fprintf(&buf, "%s%s", sep, p)
+		}
+		sep = "."
+	}
+
+	return buf.String()
+}
+
 // UnmarshalJSON implements JSON.Unmarshaler interface.
 func (v *Version) UnmarshalJSON(b []byte) error {
 	var s string
@@ -308,6 +519,7 @@ func (v *Version) UnmarshalJSON(b []byte) error {
 	v.pre = temp.pre
 	v.metadata = temp.metadata
 	v.original = temp.original
+	v.prefix = ""
 	temp = nil
 	return nil
 }
@@ -317,6 +529,16 @@ func (v *Version) MarshalJSON() ([]byte, error) {
 	return json.Marshal(v.String())
 }
 
+// isNumeric reports if a string is made up of only ASCII digits.
+func isNumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
 func compareSegment(v, o int64) int {
 	if v < o {
 		return -1
@@ -328,39 +550,25 @@ func compareSegment(v, o int64) int {
 	return 0
 }
 
+// comparePrerelease compares two pre-releases one identifier at a time. The
+// identifiers are read in place rather than split out so that comparing does
+// not allocate.
 func comparePrerelease(v, o string) int {
+	for v != "" || o != "" {
 
-	// split the prelease versions by their part. The separator, per the spec,
-	// is a .
-	sparts := strings.Split(v, ".")
-	oparts := strings.Split(o, ".")
-
-	// Find the longer length of the parts to know how many loop iterations to
-	// go through.
-	slen := len(sparts)
-	olen := len(oparts)
-
-	l := slen
-	if olen > slen {
-		l = olen
-	}
-
-	// Iterate over each part of the prereleases to compare the differences.
-	for i := 0; i < l; i++ {
-		// Since the lentgh of the parts can be different we need to create
-		// a placeholder. This is to avoid out of bounds issues.
-		stemp := ""
-		if i < slen {
-			stemp = sparts[i]
+		// When one pre-release runs out of identifiers first the one with more
+		// identifiers has the higher precedence.
+		if v == "" {
+			return -1
 		}
-
-		otemp := ""
-		if i < olen {
-			otemp = oparts[i]
+		if o == "" {
+			return 1
 		}
 
-		d := comparePrePart(stemp, otemp)
-		if d != 0 {
+		var sp, op string
+		sp, v = nextPrePart(v)
+		op, o = nextPrePart(o)
+		if d := comparePrePart(sp, op); d != 0 {
 			return d
 		}
 	}
@@ -371,51 +579,52 @@ func comparePrerelease(v, o string) int {
 	return 0
 }
 
-func comparePrePart(s, o string) int {
-	// Fastpath if they are equal
-	if s == o {
-		return 0
+// nextPrePart returns the first identifier of a pre-release and the rest of
+// the pre-release after it.
+func nextPrePart(pre string) (string, string) {
+	i := strings.IndexByte(pre, '.')
+	if i < 0 {
+		return pre, ""
 	}
+	return pre[:i], pre[i+1:]
+}
 
-	// When s or o are empty we can use the other in an attempt to determine
-	// the response.
-	if s == "" {
-		if o != "" {
-			return -1
-		}
-		return 1
+// prePartNumber returns the value of a numeric pre-release identifier. False
+// is returned for identifiers that are not numeric or do not fit in a uint64,
+// which are compared as strings.
+func prePartNumber(p string) (uint64, bool) {
+	if !isNumeric(p) {
+		return 0, false
 	}
+	n, err := strconv.ParseUint(p, 10, 64)
+	return n, err == nil
+}
 
-	if o == "" {
-		if s != "" {
-			return 1
-		}
-		return -1
+func comparePrePart(s, o string) int {
+	// Fastpath if they are equal
+	if s == o {
+		return 0
 	}
 
-	// When comparing strings "99" is greater than "103". To handle
-	// cases like this we need to detect numbers and compare them.
-
-	oi, n1 := strconv.ParseInt(o, 10, 64)
-	si, n2 := strconv.ParseInt(s, 10, 64)
-
-	// The case where both are strings compare the strings
-	if n1 != nil && n2 != nil {
-		if s > o {
+	// Numeric identifiers are compared as numbers and have a lower
+	// precedence than non-numeric ones.
+	sn, sok := prePartNumber(s)
+	on, ook := prePartNumber(o)
+	switch {
+	case sok && ook:
+		if sn > on {
 			return 1
+		} else if sn < on {
+			return -1
 		}
+		return 0
+	case sok:
+		// s is a number and o is a string
 		return -1
-	} else if n1 != nil {
-		// o is a string and s is a number
-		return -1
-	} else if n2 != nil {
+	case ook:
 		// s is a string and o is a number
 		return 1
 	}
-	// Both are numbers
-	if si > oi {
-		return 1
-	}
-	return -1
 
+	return strings.Compare(s, o)
 }
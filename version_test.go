@@ -49,6 +49,168 @@ func TestNewVersion(t *testing.T) {
 	}
 }
 
+func TestNewVersionWithOptions(t *testing.T) {
+	tests := []struct {
+		version  string
+		prefixes []string
+		expected string
+		err      bool
+	}{
+		{"release-1.2.3", []string{"release-"}, "1.2.3", false},
+		{"ver2.0.0", []string{"ver"}, "2.0.0", false},
+		{"ver2.0.0", []string{"release-", "ver"}, "2.0.0", false},
+		{"release-1.2.3-beta.1+build", []string{"release-"}, "1.2.3-beta.1+build", false},
+		{"1.2.3", []string{"release-"}, "1.2.3", false},
+		{"v1.2.3", nil, "1.2.3", false},
+		{"1.2.3", nil, "1.2.3", false},
+		{"release-1.2.3", nil, "", true},
+		{"vv1.2.3", nil, "", true},
+		{"vv1.2.3", []string{}, "", true},
+		{"ver2.0.0", []string{"release-"}, "", true},
+		{"release-", []string{"release-"}, "", true},
+	}
+
+	for _, tc := range tests {
+		v, err := NewVersionWithOptions(tc.version, VersionOptions{Prefixes: tc.prefixes})
+		if tc.err {
+			if err == nil {
+				t.Errorf("Expected error for version %s with prefixes %v", tc.version, tc.prefixes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Error parsing version %s with prefixes %v: %s", tc.version, tc.prefixes, err)
+			continue
+		}
+
+		if a := v.String(); a != tc.expected {
+			t.Errorf("Expected %q but got %q", tc.expected, a)
+		}
+		if a := v.Original(); a != tc.version {
+			t.Errorf("Expected original %q but got %q", tc.version, a)
+		}
+	}
+
+	v, err := NewVersionWithOptions("release-1.2.3", VersionOptions{Prefixes: []string{"release-"}})
+	if err != nil {
+		t.Fatalf("Error parsing version: %s", err)
+	}
+	if err := v.Validate(); err != nil {
+		t.Errorf("Unexpected validation error: %s", err)
+	}
+	n := v.IncMinor()
+	if a := n.Original(); a != "release-1.3.0" {
+		t.Errorf("Expected increment to keep the prefix but got %q", a)
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected string
+		err      bool
+	}{
+		{"v1.2.3", "1.2.3", false},
+		{"1.2.3", "1.2.3", false},
+		{"release/v1.2.3", "1.2.3", false},
+		{"refs/tags/release/1.2.3", "1.2.3", false},
+		{"app-1.2.3", "1.2.3", false},
+		{"my-app-v1.2.3-rc.1", "1.2.3-rc.1", false},
+		{"my_app_2.0.0+build.5", "2.0.0+build.5", false},
+		{"app2-1.0.0", "1.0.0", false},
+		{"pkg@1.4.0", "1.4.0", false},
+		{"v1.2.3-beta-2", "1.2.3-beta-2", false},
+		{"app-v1.2", "1.2.0", false},
+		{"release-2023-1.2.3", "1.2.3", false},
+		{"app-v2-1.0.0", "1.0.0", false},
+		{"app-v2-1.0", "2.0.0-1.0", false},
+		{"2023-10-1.2.3-rc.1", "1.2.3-rc.1", false},
+		{"app-1.2.3.4", "", true},
+		{"release/latest", "", true},
+		{"app1.2.3", "", true},
+		{"", "", true},
+	}
+
+	for _, tc := range tests {
+		v, err := ParseTag(tc.tag)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Expected error for tag %q", tc.tag)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Error parsing tag %q: %s", tc.tag, err)
+			continue
+		}
+
+		if a := v.String(); a != tc.expected {
+			t.Errorf("Expected %q for tag %q but got %q", tc.expected, tc.tag, a)
+		}
+		if a := v.Original(); a != tc.tag {
+			t.Errorf("Expected original %q but got %q", tc.tag, a)
+		}
+	}
+
+	v, err := ParseTag("release/v1.2.3")
+	if err != nil {
+		t.Fatalf("Error parsing tag: %s", err)
+	}
+	n := v.IncPatch()
+	if a := n.Original(); a != "release/v1.2.4" {
+		t.Errorf("Expected increment to keep the prefix but got %q", a)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		version string
+		err     bool
+	}{
+		{"1.2.0", false},
+		{"v1.2.0", false},
+		{"0.0.0", false},
+		{"1.2.0-0", false},
+		{"1.2.0-0a", false},
+		{"1.2.0-alpha.0.10+build.01", false},
+		{"1.2.0-x-y-z.--", false},
+		{"1.2", true},
+		{"v1.2", true},
+		{"1", true},
+		{"1.2-beta", true},
+		{"01.2.3", true},
+		{"1.02.3", true},
+		{"1.2.03", true},
+		{"1.2.3-01", true},
+		{"1.2.3-alpha.00", true},
+	}
+
+	for _, tc := range tests {
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("Error parsing version %s: %s", tc.version, err)
+			continue
+		}
+
+		err = v.Validate()
+		if tc.err && err == nil {
+			t.Errorf("expected validation error for version: %s", tc.version)
+		} else if !tc.err && err != nil {
+			t.Errorf("validation error for version %s: %s", tc.version, err)
+		}
+	}
+
+	v := MustParse("1.2").IncPatch()
+	if err := v.Validate(); err != nil {
+		t.Errorf("validation error for incremented version %s: %s", v.Original(), err)
+	}
+
+	var z Version
+	if err := z.Validate(); err != nil {
+		t.Errorf("validation error for zero version: %s", err)
+	}
+}
+
 func TestOriginal(t *testing.T) {
 	tests := []string{
 		"1.2.3",
@@ -67,6 +229,8 @@ func TestOriginal(t *testing.T) {
 		"v1.2.0-x.Y.0+metadata-width-hypen",
 		"1.2.3-rc1-with-hypen",
 		"v1.2.3-rc1-with-hypen",
+		"v2.0.0+incompatible",
+		"v2.1.0-beta.1+incompatible",
 	}
 
 	for _, tc := range tests {
@@ -105,6 +269,74 @@ func TestParts(t *testing.T) {
 	}
 }
 
+func TestPrereleaseChannel(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"1.2.3", ""},
+		{"1.2.3+sha.deadbeef", ""},
+		{"1.2.3-rc", "rc"},
+		{"1.2.3-rc.1", "rc"},
+		{"1.2.3-internal+sha.deadbeef.branch.main", "internal"},
+		{"1.2.3-internal.4.x-y+sha.deadbeef", "internal"},
+		{"1.2.3-x-y.1", "x-y"},
+	}
+
+	for _, tc := range tests {
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("Error parsing version %s", tc.version)
+		}
+
+		a := v.PrereleaseChannel()
+		if a != tc.expected {
+			t.Errorf("Expected channel %q for %s but got %q", tc.expected, tc.version, a)
+		}
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	var z Version
+	if !z.IsZero() {
+		t.Error("Expected the zero value to be zero")
+	}
+	if z.String() != "0.0.0" {
+		t.Errorf("Expected the zero value to be 0.0.0 but got %s", z.String())
+	}
+
+	tests := []struct {
+		version  string
+		expected bool
+		compare  int
+	}{
+		{"0.0.0", true, 0},
+		{"v0.0.0", true, 0},
+		{"0", true, 0},
+		{"0.0.1", false, -1},
+		{"0.1.0", false, -1},
+		{"1.0.0", false, -1},
+		{"0.0.0-alpha", false, 1},
+		{"0.0.0+build.1", false, 0},
+	}
+
+	for _, tc := range tests {
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("Error parsing version %s", tc.version)
+		}
+
+		if a := v.IsZero(); a != tc.expected {
+			t.Errorf("Expected IsZero for %s to be %t", tc.version, tc.expected)
+		}
+
+		if a := z.Compare(v); a != tc.compare {
+			t.Errorf("Comparison of the zero value and '%s' failed. Expected '%d', got '%d'",
+				tc.version, tc.compare, a)
+		}
+	}
+}
+
 func TestString(t *testing.T) {
 	tests := []struct {
 		version  string
@@ -126,6 +358,8 @@ func TestString(t *testing.T) {
 		{"v1.2.0-x.Y.0+metadata-width-hypen", "1.2.0-x.Y.0+metadata-width-hypen"},
 		{"1.2.3-rc1-with-hypen", "1.2.3-rc1-with-hypen"},
 		{"v1.2.3-rc1-with-hypen", "1.2.3-rc1-with-hypen"},
+		{"v2.0.0+incompatible", "2.0.0+incompatible"},
+		{"v2.1.0-beta.1+incompatible", "2.1.0-beta.1+incompatible"},
 	}
 
 	for _, tc := range tests {
@@ -141,6 +375,32 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestStringWithoutMetadata(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2", "1.2.0"},
+		{"1.2.3+build.123", "1.2.3"},
+		{"1.2.3-beta.1", "1.2.3-beta.1"},
+		{"v1.2.3-beta.1+build.123", "1.2.3-beta.1"},
+		{"1.2.0-x.Y.0+metadata-width-hypen", "1.2.0-x.Y.0"},
+	}
+
+	for _, tc := range tests {
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("Error parsing version %s", tc.version)
+		}
+
+		s := v.StringWithoutMetadata()
+		if s != tc.expected {
+			t.Errorf("Error generating string. Expected '%s' but got '%s'", tc.expected, s)
+		}
+	}
+}
+
 func TestCompare(t *testing.T) {
 	tests := []struct {
 		v1       string
@@ -163,6 +423,15 @@ func TestCompare(t *testing.T) {
 		{"4.2-beta.2", "4.2-beta", 1},
 		{"4.2-beta.foo", "4.2-beta", 1},
 		{"1.2+bar", "1.2+baz", 0},
+		{"1", "1.0", 0},
+		{"1", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"v1.2", "1.2.0", 0},
+		{"1.2-beta", "1.2.0-beta", 0},
+		{"v2.0.0+incompatible", "v2.0.0", 0},
+		{"v2.0.1+incompatible", "v2.0.0", 1},
+		{"v2.0.0-beta+incompatible", "v2.0.0", -1},
 	}
 
 	for _, tc := range tests {
@@ -187,6 +456,171 @@ func TestCompare(t *testing.T) {
 	}
 }
 
+// Version must stay comparable so it can be used as a map key and with ==.
+var _ = map[Version]bool{}
+
+func TestVersionComparable(t *testing.T) {
+	v1 := MustParse("1.2.3-beta.1")
+	v2 := MustParse("1.2.3-beta.1")
+	if *v1 != *v2 {
+		t.Error("Expected versions parsed from the same string to be ==")
+	}
+
+	m := map[Version]bool{*v1: true}
+	if !m[*v2] {
+		t.Error("Expected version to be found as a map key")
+	}
+	if m[*MustParse("1.2.3-beta.2")] {
+		t.Error("Unexpected version found as a map key")
+	}
+}
+
+func TestComparePrereleaseIDs(t *testing.T) {
+	tests := []struct {
+		v1       string
+		v2       string
+		expected int
+	}{
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.1", 0},
+		{"1.0.0-rc.01", "1.0.0-rc.1", 0},
+		{"1.0.0-1", "1.0.0-a", -1},
+		{"1.0.0-18446744073709551615", "1.0.0-18446744073709551614", 1},
+		{"1.0.0-99999999999999999999", "1.0.0-1", 1},
+		{"1.0.0-BETA", "1.0.0-alpha", -1},
+	}
+
+	for _, tc := range tests {
+		for _, how := range []string{"parsed", "literal", "set"} {
+			var v1, v2 *Version
+			switch how {
+			case "parsed":
+				v1, v2 = MustParse(tc.v1), MustParse(tc.v2)
+			case "literal":
+				// Versions built inside the package without NewVersion
+				// compare the same as parsed ones.
+				v1 = &Version{major: 1, pre: MustParse(tc.v1).Prerelease()}
+				v2 = &Version{major: 1, pre: MustParse(tc.v2).Prerelease()}
+			case "set":
+				a, _ := MustParse("1.0.0-x.1").SetPrerelease(MustParse(tc.v1).Prerelease())
+				b, _ := MustParse("1.0.0").SetPrerelease(MustParse(tc.v2).Prerelease())
+				v1, v2 = &a, &b
+			}
+
+			if a := v1.Compare(v2); a != tc.expected {
+				t.Errorf("Comparison of %s '%s' and '%s' failed. Expected '%d', got '%d'",
+					how, tc.v1, tc.v2, tc.expected, a)
+			}
+			if a := v2.Compare(v1); a != -tc.expected {
+				t.Errorf("Comparison of %s '%s' and '%s' failed. Expected '%d', got '%d'",
+					how, tc.v2, tc.v1, -tc.expected, a)
+			}
+		}
+	}
+}
+
+func TestHyphenatedPrerelease(t *testing.T) {
+	tests := []struct {
+		version    string
+		prerelease string
+		metadata   string
+	}{
+		{"1.0.0-x-y-z.1", "x-y-z.1", ""},
+		{"v1.0.0-x-y.1", "x-y.1", ""},
+		{"1.0.0-x-y-z.1+build-1.a-b", "x-y-z.1", "build-1.a-b"},
+		{"1.0.0--", "-", ""},
+		{"1.0.0--1", "-1", ""},
+		{"1.0.0-1-2", "1-2", ""},
+		{"1.0.0-rc-1.2-3", "rc-1.2-3", ""},
+	}
+
+	for _, tc := range tests {
+		v, err := NewVersion(tc.version)
+		if err != nil {
+			t.Errorf("Error parsing version %s: %s", tc.version, err)
+			continue
+		}
+
+		if a := v.Prerelease(); a != tc.prerelease {
+			t.Errorf("Expected prerelease %q for %s but got %q", tc.prerelease, tc.version, a)
+		}
+		if a := v.Metadata(); a != tc.metadata {
+			t.Errorf("Expected metadata %q for %s but got %q", tc.metadata, tc.version, a)
+		}
+		if a := v.Original(); a != tc.version {
+			t.Errorf("Expected original %q but got %q", tc.version, a)
+		}
+
+		// The string form parses back to the same version.
+		r, err := NewVersion(v.String())
+		if err != nil {
+			t.Errorf("Error parsing version %s: %s", v, err)
+			continue
+		}
+		if r.String() != v.String() || !r.Equal(v) {
+			t.Errorf("Version %s did not round trip, got %s", v, r)
+		}
+	}
+
+	// Each version is lower than the one after it. Identifiers with a hyphen
+	// are never numeric, so they are compared as strings and are higher than
+	// numeric identifiers.
+	order := []string{
+		"1.0.0-1",
+		"1.0.0--",
+		"1.0.0--1",
+		"1.0.0-1-2",
+		"1.0.0-x",
+		"1.0.0-x-y",
+		"1.0.0-x-y.1",
+		"1.0.0-x-y.2",
+		"1.0.0-x-y.10",
+		"1.0.0-x-y.a",
+		"1.0.0-x-y-z",
+		"1.0.0-x-y-z.1",
+		"1.0.0",
+	}
+
+	for i := 0; i < len(order)-1; i++ {
+		v1 := MustParse(order[i])
+		v2 := MustParse(order[i+1])
+		if !v1.LessThan(v2) {
+			t.Errorf("Expected %s to be less than %s", order[i], order[i+1])
+		}
+		if v2.Compare(v1) != 1 {
+			t.Errorf("Expected %s to be greater than %s", order[i+1], order[i])
+		}
+	}
+
+	checks := []struct {
+		constraint string
+		version    string
+		check      bool
+	}{
+		{">=1.0.0-x-y.1", "1.0.0-x-y.2", true},
+		{">1.0.0-x-y.2", "1.0.0-x-y.10", true},
+		{"<1.0.0-x-y.2", "1.0.0-x-y.10", false},
+		{"1.0.0-x-y.1 - 1.0.0-x-y.3", "1.0.0-x-y.2", true},
+		{"1.0.0-x-y.1 - 1.0.0-x-y.3", "1.0.0-x-y.4", false},
+		{"=1.0.0-x-y-z.1", "1.0.0-x-y-z.1+build", true},
+	}
+
+	for _, tc := range checks {
+		c, err := NewConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			continue
+		}
+
+		if a := c.Check(MustParse(tc.version)); a != tc.check {
+			t.Errorf("Constraint %q failing with %q", tc.constraint, tc.version)
+		}
+	}
+}
+
 func TestLessThan(t *testing.T) {
 	tests := []struct {
 		v1       string
@@ -268,6 +702,12 @@ func TestEqual(t *testing.T) {
 		{"2.2.3", "1.5.1", false},
 		{"3.2-beta", "3.2-beta", true},
 		{"3.2-beta+foo", "3.2-beta+bar", true},
+		{"1", "1.0", true},
+		{"1", "1.0.0", true},
+		{"1.0", "1.0.0", true},
+		{"1.2", "1.2.0", true},
+		{"1.2", "1.2.1", false},
+		{"v2.0.0+incompatible", "v2.0.0", true},
 	}
 
 	for _, tc := range tests {
@@ -292,6 +732,166 @@ func TestEqual(t *testing.T) {
 	}
 }
 
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		v1       string
+		op       string
+		v2       string
+		expected bool
+	}{
+		{"1.2.3", "", "1.2.3", true},
+		{"1.2.4", "", "1.2.3", false},
+		{"1.2.3", "=", "1.2.3+build", true},
+		{"1.2.4", "=", "1.2.3", false},
+		{"1.2.4", "!=", "1.2.3", true},
+		{"1.2.3", "!=", "1.2.3", false},
+		{"1.2.4", ">", "1.2.3", true},
+		{"1.2.3", ">", "1.2.3", false},
+		{"1.2.2", "<", "1.2.3", true},
+		{"1.2.3", "<", "1.2.3", false},
+		{"1.2.3", ">=", "1.2.3", true},
+		{"1.2.2", ">=", "1.2.3", false},
+		{"1.2.3", "=>", "1.2.3", true},
+		{"1.2.3", "<=", "1.2.3", true},
+		{"1.2.4", "<=", "1.2.3", false},
+		{"1.2.4", "=<", "1.2.3", false},
+		{"1.2.9", "~", "1.2.3", true},
+		{"1.3.0", "~", "1.2.3", false},
+		{"1.2.9", "~>", "1.2.3", true},
+		{"1.9.0", "^", "1.2.3", true},
+		{"2.0.0", "^", "1.2.3", false},
+		{"1.2.4-beta", ">", "1.2.3", false},
+		{"1.2.4-beta", ">", "1.2.3-alpha", true},
+	}
+
+	for _, tc := range tests {
+		v1, err := NewVersion(tc.v1)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		v2, err := NewVersion(tc.v2)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		a, err := v1.Satisfies(tc.op, v2)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s' %s '%s': %s", tc.v1, tc.op, tc.v2, err)
+			continue
+		}
+		if a != tc.expected {
+			t.Errorf("Comparison of '%s' %s '%s' failed. Expected %t", tc.v1, tc.op, tc.v2, tc.expected)
+		}
+	}
+
+	for _, op := range []string{">>", "==", "!", "x"} {
+		_, err := MustParse("1.0.0").Satisfies(op, MustParse("1.0.0"))
+		if err == nil {
+			t.Errorf("Expected error for operator %q", op)
+		} else if e := "unknown operator '" + op + "'"; err.Error() != e {
+			t.Errorf("Expected error %q but got %q", e, err)
+		}
+	}
+}
+
+func TestAPICompatible(t *testing.T) {
+	tests := []struct {
+		v1       string
+		v2       string
+		expected bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"1.2.0", "1.4.0", true},
+		{"1.2.0", "1.2.7", true},
+		{"1.2.3", "1.2.3+build.1", true},
+		{"1.2.0", "1.1.0", false},
+		{"1.2.3", "1.2.2", false},
+		{"1.2.0", "2.0.0", false},
+		{"2.0.0", "1.9.0", false},
+		{"1.2.0", "1.2.0-rc.1", false},
+		{"1.2.0-rc.1", "1.2.0", true},
+	}
+
+	for _, tc := range tests {
+		v1, err := NewVersion(tc.v1)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		v2, err := NewVersion(tc.v2)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		a := v1.APICompatible(v2)
+		e := tc.expected
+		if a != e {
+			t.Errorf(
+				"Compatibility of '%s' with '%s' failed. Expected '%t', got '%t'",
+				tc.v2, tc.v1, e, a,
+			)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		v1 string
+		v2 string
+	}{
+		{"1.0.0", "1.0.0"},
+		{"1.0.0+a", "1.0.0+b"},
+		{"v1.0.0", "1.0.0+build.1"},
+		{"1", "1.0.0"},
+		{"1.0.0-rc.1", "1.0.0-rc.1+build.2"},
+		{"1.0.0-rc.01", "1.0.0-rc.1"},
+		{"1.0.0", "1.0.1"},
+		{"1.0.0", "1.1.0"},
+		{"1.0.0", "2.0.0"},
+		{"1.0.0", "1.0.0-rc.1"},
+		{"1.0.0-rc.1", "1.0.0-rc.2"},
+		{"1.0.0-rc.1", "1.0.0-rc-1"},
+		{"1.10.0", "11.0.0"},
+	}
+
+	for _, tc := range tests {
+		v1, err := NewVersion(tc.v1)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		v2, err := NewVersion(tc.v2)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		if e, a := v1.Equal(v2), v1.Hash() == v2.Hash(); e != a {
+			t.Errorf("Hashes of '%s' and '%s' should be equal: %t", tc.v1, tc.v2, e)
+		}
+	}
+
+	// The hash is stable across runs and releases. These are the FNV-1a
+	// hashes of the strings 1.2.3-beta.1 and 0.0.0.
+	pinned := []struct {
+		version string
+		hash    uint64
+	}{
+		{"1.2.3-beta.1", 0x80d01fccc3934e7d},
+		{"v1.2.3-beta.01+x", 0x80d01fccc3934e7d},
+		{"0.0.0", 0xbb61e7b0e763ee3f},
+	}
+	for _, tc := range pinned {
+		if h := MustParse(tc.version).Hash(); h != tc.hash {
+			t.Errorf("Expected hash %#x for '%s' but got %#x", tc.hash, tc.version, h)
+		}
+	}
+	var z Version
+	if z.Hash() != MustParse("0.0.0").Hash() {
+		t.Error("Hash of the zero value differs from 0.0.0")
+	}
+}
+
 func TestInc(t *testing.T) {
 	tests := []struct {
 		v1               string
@@ -488,3 +1088,60 @@ func TestJsonUnmarshal(t *testing.T) {
 		t.Errorf("Error unmarshaling unexpected object content: got=%q want=%q", got, want)
 	}
 }
+
+func TestWithBuildCounter(t *testing.T) {
+	tests := []struct {
+		v1               string
+		n                uint64
+		expectedVersion  string
+		expectedMetadata string
+		expectedOriginal string
+	}{
+		{"1.2.3", 0, "1.2.3+build.0", "build.0", "1.2.3+build.0"},
+		{"1.2.3", 42, "1.2.3+build.42", "build.42", "1.2.3+build.42"},
+		{"v1.2.3-rc.1", 7, "1.2.3-rc.1+build.7", "build.7", "v1.2.3-rc.1+build.7"},
+		{"1.2.3+sha.abc", 18446744073709551615, "1.2.3+build.18446744073709551615",
+			"build.18446744073709551615", "1.2.3+build.18446744073709551615"},
+	}
+
+	for _, tc := range tests {
+		v1, err := NewVersion(tc.v1)
+		if err != nil {
+			t.Errorf("Error parsing version: %s", err)
+		}
+
+		v2 := v1.WithBuildCounter(tc.n)
+
+		a := v2.Metadata()
+		e := tc.expectedMetadata
+		if a != e {
+			t.Errorf("Expected metadata value=%q, but got %q", e, a)
+		}
+
+		a = v2.String()
+		e = tc.expectedVersion
+		if a != e {
+			t.Errorf("Expected version string=%q, but got %q", e, a)
+		}
+
+		a = v2.Original()
+		e = tc.expectedOriginal
+		if a != e {
+			t.Errorf("Expected version original=%q, but got %q", e, a)
+		}
+
+		if v1.Major() != v2.Major() || v1.Minor() != v2.Minor() ||
+			v1.Patch() != v2.Patch() || v1.Prerelease() != v2.Prerelease() {
+			t.Errorf("Expected %s to keep the version of %s", v2.String(), tc.v1)
+		}
+
+		if v1.Compare(&v2) != 0 {
+			t.Errorf("Expected %s to be equal to %s", v2.String(), tc.v1)
+		}
+
+		v3 := v1.WithBuildCounter(tc.n + 1)
+		if v3.Compare(&v2) != 0 {
+			t.Errorf("Expected %s to be equal to %s", v3.String(), v2.String())
+		}
+	}
+}